@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// 本地控制接口的地址和令牌，保存在 api.json 中供命令行使用
+type ControlInfo struct {
+	Addr  string `json:"addr"`
+	Token string `json:"token"`
+}
+
+type ControlResponse struct {
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// 只监听本机的 HTTP 控制接口，请求需要携带 api.json 中的令牌
+type ControlServer struct {
+	ln     net.Listener
+	server *http.Server
+	mux    *http.ServeMux
+	info   ControlInfo
+}
+
+func NewControlServer() (*ControlServer, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, 16)
+	rand.Read(token)
+	s := &ControlServer{
+		ln:  ln,
+		mux: http.NewServeMux(),
+		info: ControlInfo{
+			Addr:  ln.Addr().String(),
+			Token: hex.EncodeToString(token),
+		},
+	}
+	s.server = &http.Server{Handler: s.mux, ReadHeaderTimeout: 5 * time.Second}
+
+	data, _ := json.Marshal(s.info)
+	if err := os.WriteFile(getApiPath(), data, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("本地控制接口已启动，地址为%s", s.info.Addr)}
+	return s, nil
+}
+
+// 注册接口，handler 的返回值编码为 JSON
+func (s *ControlServer) Handle(pattern string, handler func(r *http.Request) (any, error)) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.Header.Get("Authorization") != "Bearer "+s.info.Token {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(ControlResponse{Error: "令牌错误"})
+			return
+		}
+
+		result, err := handler(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ControlResponse{Error: err.Error()})
+			return
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(ControlResponse{Error: err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(ControlResponse{Data: data})
+	})
+}
+
+func (s *ControlServer) Start() {
+	go func() {
+		if err := s.server.Serve(s.ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("本地控制接口已停止: %v", err)}
+		}
+	}()
+}
+
+func (s *ControlServer) Stop() {
+	global_log_channel <- LogEntry{Kind: KindInfo, Content: "本地控制接口正在关闭..."}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.server.Shutdown(ctx)
+	os.Remove(getApiPath())
+}
+
+// 程序未运行或未启用本地控制接口，命令行可以改为读取本地保存的数据
+var errControlUnavailable = errors.New("程序未运行或未启用本地控制接口")
+
+// 调用正在运行的程序的控制接口，用于命令行
+func callControlApi(method string, path string, body any) (json.RawMessage, error) {
+	data, err := os.ReadFile(getApiPath())
+	if err != nil {
+		return nil, errControlUnavailable
+	}
+	var info ControlInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader = nil
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, "http://"+info.Addr+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+info.Token)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errControlUnavailable
+	}
+	defer resp.Body.Close()
+
+	var result ControlResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("接口返回错误: %s", resp.Status)
+	}
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+	return result.Data, nil
+}
+
+// 接口返回的条目，敏感内容不返回原文
+type ControlItem struct {
+	Group   string    `json:"group,omitempty"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Title   string    `json:"title"`
+	Content string    `json:"content,omitempty"`
+}
+
+func newControlItem(item *ClipItem, group string) ControlItem {
+	result := ControlItem{
+		Group: group,
+		Type:  item_type_names[item.Type],
+		Time:  item.Time,
+		Title: formatMenuItem(item),
+	}
+	switch {
+	case item.Sensitive:
+	case item.Type == TypeImage:
+		result.Content = ocrText(item)
+	default:
+		result.Content = string(item.Content)
+	}
+	return result
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 命令行模式，返回true表示已处理，不再启动托盘
+func runCli(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "search":
+		cliSearch(args[1:])
+	case "filter":
+		cliFilter(args[1:])
+	case "pause":
+		cliPause(args[1:])
+	case "resume":
+		cliControl("POST", "/resume")
+	case "status":
+		cliStatus()
+	case "picker":
+		cliControl("POST", "/picker")
+	default:
+		fmt.Println("用法: clip [命令]")
+		fmt.Println("")
+		fmt.Println("命令:")
+		fmt.Println("  search [-n 数量] [-group 分组] 搜索语句  - 搜索历史记录和分组")
+		fmt.Println("  filter 名称                          - 使用已保存的过滤器搜索")
+		fmt.Println("  pause [分钟]                         - 暂停记录，不指定分钟时直到手动恢复")
+		fmt.Println("  resume                               - 恢复记录")
+		fmt.Println("  status                               - 查看记录状态")
+		fmt.Println("  picker                               - 打开选择窗口")
+	}
+	return true
+}
+
+func loadLocalConfig() (*Config, error) {
+	config := NewDefaultConfig()
+	data, err := os.ReadFile(getConfigPath())
+	if err != nil {
+		return config, err
+	}
+	err = json.Unmarshal(data, config)
+	return config, err
+}
+
+// 解密加密保存的历史记录，只支持保存在系统钥匙串中的密钥
+func decryptLocalConfig(config *Config) error {
+	if !config.EncryptEnable || config.EncryptedData == nil {
+		return nil
+	}
+	if config.EncryptKeySource != KeySourceKeyring {
+		return errors.New("历史记录已使用密码加密，请在托盘菜单中解锁后搜索")
+	}
+	key, err := loadKeyringKey()
+	if err != nil {
+		return err
+	}
+	config.Data, err = decryptHistoryData(config.EncryptedData, key)
+	return err
+}
+
+func cliSearch(args []string) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	limit := flags.Int("n", 20, "最多显示的结果数量")
+	group := flags.String("group", "", "只搜索指定分组")
+	flags.Parse(args)
+
+	text := strings.Join(flags.Args(), " ")
+	query, err := ParseSearchQuery(text)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "搜索语句错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 程序正在运行时搜索内存中的记录，包含上次保存之后复制的内容
+	params := url.Values{"q": {text}, "n": {strconv.Itoa(*limit)}, "group": {*group}}
+	data, err := callControlApi("GET", "/search?"+params.Encode(), nil)
+	if err == nil {
+		var items []ControlItem
+		json.Unmarshal(data, &items)
+		for _, item := range items {
+			fmt.Printf("%s\t%s\n", Ifel(item.Group == "", "历史记录", "📂"+item.Group), item.Title)
+		}
+		return
+	}
+	if !errors.Is(err, errControlUnavailable) {
+		fmt.Fprintf(os.Stderr, "搜索失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	config, err := loadLocalConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	if err := decryptLocalConfig(config); err != nil {
+		fmt.Fprintf(os.Stderr, "读取历史记录失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	history := config.Data.History
+	groups := make(map[string][]*ClipItem)
+	for name, data := range config.Data.Groups {
+		if *group == "" || *group == name {
+			groups[name] = data.History
+		}
+	}
+	if *group != "" {
+		history = nil
+	}
+
+	query.InGroup = func(name string, item *ClipItem) bool {
+		for groupName, data := range config.Data.Groups {
+			if !strings.EqualFold(groupName, name) {
+				continue
+			}
+			for _, it := range data.History {
+				if it.Type == item.Type && it.Hash == item.Hash {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for i, result := range SearchAll(query, history, groups) {
+		if i >= *limit {
+			break
+		}
+		fmt.Printf("%s\t%s\n", Ifel(result.Group == "", "历史记录", "📂"+result.Group), formatMenuItem(result.Item))
+	}
+}
+
+func cliFilter(args []string) {
+	config, err := loadLocalConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取配置失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	name := strings.Join(args, " ")
+	for _, filter := range config.SavedFilters {
+		if filter.Name == name {
+			cliSearch([]string{"--", filter.Query})
+			return
+		}
+	}
+
+	fmt.Println("已保存的过滤器:")
+	for _, filter := range config.SavedFilters {
+		fmt.Printf("  %s\t%s\n", filter.Name, filter.Query)
+	}
+}
+
+// 调用正在运行的程序的控制接口并输出结果
+func cliControl(method string, path string) {
+	data, err := callControlApi(method, path, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	var message string
+	if json.Unmarshal(data, &message) == nil {
+		fmt.Println(message)
+	}
+}
+
+func cliPause(args []string) {
+	path := "/pause"
+	if len(args) > 0 {
+		minutes, err := strconv.Atoi(args[0])
+		if err != nil || minutes <= 0 {
+			fmt.Fprintf(os.Stderr, "无法解析分钟数: %s\n", args[0])
+			os.Exit(1)
+		}
+		path += "?minutes=" + strconv.Itoa(minutes)
+	}
+	cliControl("POST", path)
+}
+
+func cliStatus() {
+	data, err := callControlApi("GET", "/status", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	var status struct {
+		Status string `json:"status"`
+		Locked bool   `json:"locked"`
+	}
+	json.Unmarshal(data, &status)
+	fmt.Println(status.Status + Ifel(status.Locked, "，历史记录已锁定", ""))
+}
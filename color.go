@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Color struct {
+	R, G, B uint8
+	A       float64
+}
+
+type ColorFormat struct {
+	Name   string
+	Format func(c Color) string
+}
+
+// 可以复制的颜色格式
+var color_formats = []ColorFormat{
+	{Name: "Hex", Format: func(c Color) string {
+		if c.A < 1 {
+			return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, uint8(math.Round(c.A*255)))
+		}
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}},
+	{Name: "RGB", Format: func(c Color) string {
+		return fmt.Sprintf("%d,%d,%d", c.R, c.G, c.B)
+	}},
+	{Name: "rgb()", Format: func(c Color) string {
+		if c.A < 1 {
+			return fmt.Sprintf("rgba(%d, %d, %d, %s)", c.R, c.G, c.B, formatFloat(c.A))
+		}
+		return fmt.Sprintf("rgb(%d, %d, %d)", c.R, c.G, c.B)
+	}},
+	{Name: "hsl()", Format: func(c Color) string {
+		h, s, l := c.HSL()
+		if c.A < 1 {
+			return fmt.Sprintf("hsla(%d, %d%%, %d%%, %s)", int(math.Round(h)), int(math.Round(s*100)), int(math.Round(l*100)), formatFloat(c.A))
+		}
+		return fmt.Sprintf("hsl(%d, %d%%, %d%%)", int(math.Round(h)), int(math.Round(s*100)), int(math.Round(l*100)))
+	}},
+	{Name: "HSV", Format: func(c Color) string {
+		h, s, v := c.HSV()
+		return fmt.Sprintf("hsv(%d, %d%%, %d%%)", int(math.Round(h)), int(math.Round(s*100)), int(math.Round(v*100)))
+	}},
+	{Name: "0xAARRGGBB", Format: func(c Color) string {
+		return fmt.Sprintf("0x%02X%02X%02X%02X", uint8(math.Round(c.A*255)), c.R, c.G, c.B)
+	}},
+	{Name: "浮点数", Format: func(c Color) string {
+		text := fmt.Sprintf("%s, %s, %s", formatFloat(float64(c.R)/255), formatFloat(float64(c.G)/255), formatFloat(float64(c.B)/255))
+		if c.A < 1 {
+			text += ", " + formatFloat(c.A)
+		}
+		return text
+	}},
+	{Name: "颜色名称", Format: func(c Color) string {
+		return c.Name()
+	}},
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1000)/1000, 'f', -1, 64)
+}
+
+func (c Color) HSL() (h, s, l float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	maxC, minC := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l = (maxC + minC) / 2
+	d := maxC - minC
+	if d == 0 {
+		return 0, 0, l
+	}
+	s = d / (1 - math.Abs(2*l-1))
+	return hue(r, g, b, maxC, d), s, l
+}
+
+func (c Color) HSV() (h, s, v float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	maxC, minC := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	d := maxC - minC
+	if maxC == 0 {
+		return 0, 0, 0
+	}
+	if d == 0 {
+		return 0, 0, maxC
+	}
+	return hue(r, g, b, maxC, d), d / maxC, maxC
+}
+
+func hue(r, g, b, maxC, d float64) float64 {
+	var h float64
+	switch maxC {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// 完全一致的CSS颜色名称，没有时为空
+func (c Color) Name() string {
+	if c.A < 1 {
+		return ""
+	}
+	for _, name := range colorNames() {
+		n := css_named_colors[name]
+		if n.R == c.R && n.G == c.G && n.B == c.B {
+			return name
+		}
+	}
+	return ""
+}
+
+var colorNames = sync.OnceValue(func() []string {
+	names := make([]string, 0, len(css_named_colors))
+	for name := range css_named_colors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+})
+
+func fromHSL(h, s, l float64) Color {
+	c := (1 - math.Abs(2*l-1)) * s
+	return fromChroma(h, c, l-c/2)
+}
+
+func fromHSV(h, s, v float64) Color {
+	c := v * s
+	return fromChroma(h, c, v-c)
+}
+
+func fromChroma(h, c, m float64) Color {
+	h = math.Mod(math.Mod(h, 360)+360, 360) / 60
+	x := c * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g, b = c, x, 0
+	case 1:
+		r, g, b = x, c, 0
+	case 2:
+		r, g, b = 0, c, x
+	case 3:
+		r, g, b = 0, x, c
+	case 4:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return Color{R: toByte(r + m), G: toByte(g + m), B: toByte(b + m), A: 1}
+}
+
+func toByte(f float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, f)) * 255))
+}
+
+var (
+	hexColorPattern   = regexp.MustCompile(`(?i)^#([\da-f]{3,4}|[\da-f]{6}|[\da-f]{8})$`)
+	argbColorPattern  = regexp.MustCompile(`(?i)^0x([\da-f]{6}|[\da-f]{8})$`)
+	funcColorPattern  = regexp.MustCompile(`(?i)^(rgba?|hsla?|hsv|hsb)\(\s*([^)]*)\)$`)
+	tupleColorPattern = regexp.MustCompile(`^\(?\s*([\d.]+%?(?:\s*,\s*[\d.]+%?){2,3})\s*\)?$`)
+	colorArgSplitter  = regexp.MustCompile(`\s*[,/]\s*|\s+`)
+)
+
+// 识别颜色，支持 #RGB[A]、#RRGGBB[AA]、rgb()/rgba()、hsl()/hsla()、hsv()、
+// 0xAARRGGBB、"r,g,b[,a]" 整数和 "0.1, 0.2, 0.3[, a]" 浮点数
+// CSS颜色名称与普通单词相同，只在开启识别颜色名称时识别
+func parseColor(text string) (Color, bool) {
+	text = strings.TrimSpace(text)
+
+	if groups := hexColorPattern.FindStringSubmatch(text); groups != nil {
+		hex := groups[1]
+		if len(hex) <= 4 {
+			expanded := ""
+			for _, r := range hex {
+				expanded += string(r) + string(r)
+			}
+			hex = expanded
+		}
+		n, _ := strconv.ParseUint(hex, 16, 32)
+		if len(hex) == 6 {
+			return Color{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: 1}, true
+		}
+		return Color{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: float64(uint8(n)) / 255}, true
+	}
+
+	if groups := argbColorPattern.FindStringSubmatch(text); groups != nil {
+		n, _ := strconv.ParseUint(groups[1], 16, 32)
+		a := Ifel(len(groups[1]) == 8, float64(uint8(n>>24))/255, 1.0)
+		return Color{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: a}, true
+	}
+
+	if c, ok := css_named_colors[strings.ToLower(text)]; ok && config_recognize_named_color {
+		return c, true
+	}
+
+	if groups := funcColorPattern.FindStringSubmatch(text); groups != nil {
+		args := colorArgSplitter.Split(strings.TrimSpace(groups[2]), -1)
+		return parseColorFunc(strings.ToLower(groups[1]), args)
+	}
+
+	if groups := tupleColorPattern.FindStringSubmatch(text); groups != nil {
+		args := colorArgSplitter.Split(groups[1], -1)
+		if strings.Contains(groups[1], ".") {
+			return parseFloatTuple(args)
+		}
+		return parseColorFunc("rgb", args)
+	}
+
+	return Color{}, false
+}
+
+// 解析颜色分量，百分比按 scale 换算
+func parseColorArg(arg string, scale float64) (float64, bool) {
+	arg = strings.TrimSuffix(strings.ToLower(arg), "deg")
+	percent := strings.HasSuffix(arg, "%")
+	f, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+	if err != nil {
+		return 0, false
+	}
+	if percent {
+		return f / 100 * scale, true
+	}
+	return f, true
+}
+
+func parseAlpha(args []string) (float64, bool) {
+	if len(args) < 4 {
+		return 1, true
+	}
+	a, ok := parseColorArg(args[3], 1)
+	// "r,g,b,a" 中的透明度可能是 0-255 的整数
+	if ok && a > 1 && a <= 255 && !strings.ContainsAny(args[3], ".%") {
+		a /= 255
+	}
+	return a, ok && a >= 0 && a <= 1
+}
+
+func parseColorFunc(name string, args []string) (Color, bool) {
+	if len(args) != 3 && len(args) != 4 {
+		return Color{}, false
+	}
+	a, ok := parseAlpha(args)
+	if !ok {
+		return Color{}, false
+	}
+
+	switch name {
+	case "rgb", "rgba":
+		values := [3]uint8{}
+		for i := range 3 {
+			v, ok := parseColorArg(args[i], 255)
+			if !ok || v < 0 || v > 255 {
+				return Color{}, false
+			}
+			values[i] = uint8(math.Round(v))
+		}
+		return Color{R: values[0], G: values[1], B: values[2], A: a}, true
+	default:
+		h, ok1 := parseColorArg(args[0], 360)
+		s, ok2 := parseColorArg(args[1], 1)
+		x, ok3 := parseColorArg(args[2], 1)
+		if !ok1 || !ok2 || !ok3 || s < 0 || s > 1 || x < 0 || x > 1 {
+			return Color{}, false
+		}
+		c := Ifel(name == "hsv" || name == "hsb", fromHSV(h, s, x), fromHSL(h, s, x))
+		c.A = a
+		return c, true
+	}
+}
+
+func parseFloatTuple(args []string) (Color, bool) {
+	if len(args) != 3 && len(args) != 4 {
+		return Color{}, false
+	}
+	values := [4]float64{1, 1, 1, 1}
+	for i, arg := range args {
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil || f < 0 || f > 1 {
+			return Color{}, false
+		}
+		values[i] = f
+	}
+	return Color{R: toByte(values[0]), G: toByte(values[1]), B: toByte(values[2]), A: values[3]}, true
+}
+
+var colorSwatches sync.Map
+
+// 生成颜色色块图标
+func colorSwatch(c Color) []byte {
+	if swatch, ok := colorSwatches.Load(c); ok {
+		return swatch.([]byte)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := range 16 {
+		for x := range 16 {
+			// 边框
+			if x == 0 || y == 0 || x == 15 || y == 15 {
+				img.Set(x, y, color.NRGBA{128, 128, 128, 255})
+			} else {
+				img.Set(x, y, color.NRGBA{c.R, c.G, c.B, uint8(math.Round(c.A * 255))})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil
+	}
+	colorSwatches.Store(c, buf.Bytes())
+	return buf.Bytes()
+}
+
+var css_named_colors = map[string]Color{
+	"aliceblue": {240, 248, 255, 1}, "antiquewhite": {250, 235, 215, 1}, "aqua": {0, 255, 255, 1},
+	"aquamarine": {127, 255, 212, 1}, "azure": {240, 255, 255, 1}, "beige": {245, 245, 220, 1},
+	"bisque": {255, 228, 196, 1}, "black": {0, 0, 0, 1}, "blanchedalmond": {255, 235, 205, 1},
+	"blue": {0, 0, 255, 1}, "blueviolet": {138, 43, 226, 1}, "brown": {165, 42, 42, 1},
+	"burlywood": {222, 184, 135, 1}, "cadetblue": {95, 158, 160, 1}, "chartreuse": {127, 255, 0, 1},
+	"chocolate": {210, 105, 30, 1}, "coral": {255, 127, 80, 1}, "cornflowerblue": {100, 149, 237, 1},
+	"cornsilk": {255, 248, 220, 1}, "crimson": {220, 20, 60, 1}, "cyan": {0, 255, 255, 1},
+	"darkblue": {0, 0, 139, 1}, "darkcyan": {0, 139, 139, 1}, "darkgoldenrod": {184, 134, 11, 1},
+	"darkgray": {169, 169, 169, 1}, "darkgreen": {0, 100, 0, 1}, "darkgrey": {169, 169, 169, 1},
+	"darkkhaki": {189, 183, 107, 1}, "darkmagenta": {139, 0, 139, 1}, "darkolivegreen": {85, 107, 47, 1},
+	"darkorange": {255, 140, 0, 1}, "darkorchid": {153, 50, 204, 1}, "darkred": {139, 0, 0, 1},
+	"darksalmon": {233, 150, 122, 1}, "darkseagreen": {143, 188, 143, 1}, "darkslateblue": {72, 61, 139, 1},
+	"darkslategray": {47, 79, 79, 1}, "darkslategrey": {47, 79, 79, 1}, "darkturquoise": {0, 206, 209, 1},
+	"darkviolet": {148, 0, 211, 1}, "deeppink": {255, 20, 147, 1}, "deepskyblue": {0, 191, 255, 1},
+	"dimgray": {105, 105, 105, 1}, "dimgrey": {105, 105, 105, 1}, "dodgerblue": {30, 144, 255, 1},
+	"firebrick": {178, 34, 34, 1}, "floralwhite": {255, 250, 240, 1}, "forestgreen": {34, 139, 34, 1},
+	"fuchsia": {255, 0, 255, 1}, "gainsboro": {220, 220, 220, 1}, "ghostwhite": {248, 248, 255, 1},
+	"gold": {255, 215, 0, 1}, "goldenrod": {218, 165, 32, 1}, "gray": {128, 128, 128, 1},
+	"green": {0, 128, 0, 1}, "greenyellow": {173, 255, 47, 1}, "grey": {128, 128, 128, 1},
+	"honeydew": {240, 255, 240, 1}, "hotpink": {255, 105, 180, 1}, "indianred": {205, 92, 92, 1},
+	"indigo": {75, 0, 130, 1}, "ivory": {255, 255, 240, 1}, "khaki": {240, 230, 140, 1},
+	"lavender": {230, 230, 250, 1}, "lavenderblush": {255, 240, 245, 1}, "lawngreen": {124, 252, 0, 1},
+	"lemonchiffon": {255, 250, 205, 1}, "lightblue": {173, 216, 230, 1}, "lightcoral": {240, 128, 128, 1},
+	"lightcyan": {224, 255, 255, 1}, "lightgoldenrodyellow": {250, 250, 210, 1}, "lightgray": {211, 211, 211, 1},
+	"lightgreen": {144, 238, 144, 1}, "lightgrey": {211, 211, 211, 1}, "lightpink": {255, 182, 193, 1},
+	"lightsalmon": {255, 160, 122, 1}, "lightseagreen": {32, 178, 170, 1}, "lightskyblue": {135, 206, 250, 1},
+	"lightslategray": {119, 136, 153, 1}, "lightslategrey": {119, 136, 153, 1}, "lightsteelblue": {176, 196, 222, 1},
+	"lightyellow": {255, 255, 224, 1}, "lime": {0, 255, 0, 1}, "limegreen": {50, 205, 50, 1},
+	"linen": {250, 240, 230, 1}, "magenta": {255, 0, 255, 1}, "maroon": {128, 0, 0, 1},
+	"mediumaquamarine": {102, 205, 170, 1}, "mediumblue": {0, 0, 205, 1}, "mediumorchid": {186, 85, 211, 1},
+	"mediumpurple": {147, 112, 219, 1}, "mediumseagreen": {60, 179, 113, 1}, "mediumslateblue": {123, 104, 238, 1},
+	"mediumspringgreen": {0, 250, 154, 1}, "mediumturquoise": {72, 209, 204, 1}, "mediumvioletred": {199, 21, 133, 1},
+	"midnightblue": {25, 25, 112, 1}, "mintcream": {245, 255, 250, 1}, "mistyrose": {255, 228, 225, 1},
+	"moccasin": {255, 228, 181, 1}, "navajowhite": {255, 222, 173, 1}, "navy": {0, 0, 128, 1},
+	"oldlace": {253, 245, 230, 1}, "olive": {128, 128, 0, 1}, "olivedrab": {107, 142, 35, 1},
+	"orange": {255, 165, 0, 1}, "orangered": {255, 69, 0, 1}, "orchid": {218, 112, 214, 1},
+	"palegoldenrod": {238, 232, 170, 1}, "palegreen": {152, 251, 152, 1}, "paleturquoise": {175, 238, 238, 1},
+	"palevioletred": {219, 112, 147, 1}, "papayawhip": {255, 239, 213, 1}, "peachpuff": {255, 218, 185, 1},
+	"peru": {205, 133, 63, 1}, "pink": {255, 192, 203, 1}, "plum": {221, 160, 221, 1},
+	"powderblue": {176, 224, 230, 1}, "purple": {128, 0, 128, 1}, "rebeccapurple": {102, 51, 153, 1},
+	"red": {255, 0, 0, 1}, "rosybrown": {188, 143, 143, 1}, "royalblue": {65, 105, 225, 1},
+	"saddlebrown": {139, 69, 19, 1}, "salmon": {250, 128, 114, 1}, "sandybrown": {244, 164, 96, 1},
+	"seagreen": {46, 139, 87, 1}, "seashell": {255, 245, 238, 1}, "sienna": {160, 82, 45, 1},
+	"silver": {192, 192, 192, 1}, "skyblue": {135, 206, 235, 1}, "slateblue": {106, 90, 205, 1},
+	"slategray": {112, 128, 144, 1}, "slategrey": {112, 128, 144, 1}, "snow": {255, 250, 250, 1},
+	"springgreen": {0, 255, 127, 1}, "steelblue": {70, 130, 180, 1}, "tan": {210, 180, 140, 1},
+	"teal": {0, 128, 128, 1}, "thistle": {216, 191, 216, 1}, "tomato": {255, 99, 71, 1},
+	"turquoise": {64, 224, 208, 1}, "violet": {238, 130, 238, 1}, "wheat": {245, 222, 179, 1},
+	"white": {255, 255, 255, 1}, "whitesmoke": {245, 245, 245, 1}, "yellow": {255, 255, 0, 1},
+	"yellowgreen": {154, 205, 50, 1},
+}
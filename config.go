@@ -3,6 +3,7 @@ package main
 type HistoryGroupData struct{
 	Active bool `json:"active"`
 	History []*ClipItem `json:"history"`
+	Retention RetentionPolicy `json:"retention"`
 }
 
 type HistoryData struct{
@@ -11,11 +12,43 @@ type HistoryData struct{
 	GroupNames []string `json:"group_names"`
 }
 
+type SavedFilter struct{
+	Name string `json:"name"`
+	Query string `json:"query"`
+}
+
 type Config struct{
 	HistoryMax uint `json:"history_max"`
 	SingleDelete bool `json:"single_delete"`
 	AutoRecognizeColor bool `json:"auto_recognize_color"`
+	AutoRecognizeTime bool `json:"auto_recognize_time"`
+	RecognizeNamedColor bool `json:"recognize_named_color"`
 	SaveLogToLocal bool `json:"save_log_to_local"`
+	ShareDownloadDir string `json:"share_download_dir"`
+	SavedFilters []SavedFilter `json:"saved_filters"`
+	OcrEnable bool `json:"ocr_enable"`
+	OcrEngine string `json:"ocr_engine"`
+	OcrCommand string `json:"ocr_command"`
+	OcrLanguage string `json:"ocr_language"`
+	MenuThumbnail bool `json:"menu_thumbnail"`
+	Snippets []Snippet `json:"snippets"`
+	DedupePolicy DedupePolicy `json:"dedupe_policy"`
+	HistoryOrder HistoryOrder `json:"history_order"`
+	SensitiveDetect bool `json:"sensitive_detect"`
+	SensitiveExpire uint `json:"sensitive_expire"`
+	EncryptEnable bool `json:"encrypt_enable"`
+	EncryptKeySource string `json:"encrypt_key_source"`
+	EncryptedData *EncryptedData `json:"encrypted_data,omitempty"`
+	IgnoreApps []string `json:"ignore_apps"`
+	IgnorePatterns []string `json:"ignore_patterns"`
+	ControlApi bool `json:"control_api"`
+	Retention RetentionPolicy `json:"retention"`
+	MenuPageSize uint `json:"menu_page_size"`
+	MenuRecentCount uint `json:"menu_recent_count"`
+	HotkeyEnable bool `json:"hotkey_enable"`
+	Hotkeys HotkeyConfig `json:"hotkeys"`
+	PickerFont string `json:"picker_font"`
+	AutoPaste AutoPasteConfig `json:"auto_paste"`
 	Data HistoryData `json:"data"`
 }
 
@@ -24,7 +57,38 @@ func NewDefaultConfig() *Config{
 		HistoryMax: 50,
 		SingleDelete: false,
 		AutoRecognizeColor: false,
+		AutoRecognizeTime: false,
+		RecognizeNamedColor: false,
 		SaveLogToLocal: false,
+		ShareDownloadDir: "",
+		SavedFilters: []SavedFilter{},
+		OcrEnable: false,
+		OcrEngine: "tesseract",
+		OcrCommand: "tesseract",
+		OcrLanguage: "chi_sim+eng",
+		MenuThumbnail: true,
+		Snippets: []Snippet{},
+		DedupePolicy: DedupeTop,
+		HistoryOrder: OrderTime,
+		SensitiveDetect: true,
+		SensitiveExpire: 0,
+		EncryptEnable: false,
+		EncryptKeySource: KeySourceKeyring,
+		EncryptedData: nil,
+		IgnoreApps: default_ignore_apps,
+		IgnorePatterns: []string{},
+		ControlApi: true,
+		Retention: RetentionPolicy{},
+		MenuPageSize: 30,
+		MenuRecentCount: 10,
+		HotkeyEnable: false,
+		Hotkeys: HotkeyConfig{
+			Menu: "ctrl+shift+v",
+			PasteNth: "ctrl+alt",
+			Cycle: "ctrl+alt+v",
+		},
+		PickerFont: "",
+		AutoPaste: AutoPasteConfig{},
 		Data: HistoryData{
 			History: nil,
 			Groups: make(map[string]HistoryGroupData),
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/zalando/go-keyring"
+)
+
+// 密钥来源
+const (
+	KeySourcePassphrase = "passphrase" // 由用户密码生成
+	KeySourceKeyring    = "keyring"    // 随机生成并保存在系统钥匙串
+)
+
+const (
+	keyring_service = "clip"
+	keyring_user    = "history"
+	pbkdf2_iter     = 200000
+	min_passphrase  = 6
+	// 点击菜单后等待复制密码的时间
+	passphrase_timeout = time.Minute
+)
+
+// 等待从剪贴板读取密码的回调，读取的密码不进入历史记录、分组和局域网共享
+var global_passphrase_waiter atomic.Pointer[func(passphrase string)]
+
+// 等待用户复制密码，超时后取消
+func awaitPassphrase(callback func(passphrase string)) {
+	waiter := &callback
+	global_passphrase_waiter.Store(waiter)
+	message := fmt.Sprintf("请在%d秒内复制密码", int(passphrase_timeout.Seconds()))
+	global_log_channel <- LogEntry{Kind: KindInfo, Content: message}
+	if err := notify("Clip", message); err != nil {
+		global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("显示通知失败: %v", err)}
+	}
+	time.AfterFunc(passphrase_timeout, func() {
+		if global_passphrase_waiter.CompareAndSwap(waiter, nil) {
+			reportError("等待复制密码超时")
+		}
+	})
+}
+
+// 有等待中的密码时将剪贴板文本用作密码，返回true表示已用作密码
+func takePassphrase(item *ClipItem) bool {
+	if item.Type != TypeText {
+		return false
+	}
+	waiter := global_passphrase_waiter.Swap(nil)
+	if waiter == nil {
+		return false
+	}
+	global_log_channel <- LogEntry{Kind: KindInfo, Content: "已从剪贴板读取密码"}
+	(*waiter)(string(item.Content))
+	return true
+}
+
+// 加密后的历史记录和分组
+type EncryptedData struct {
+	Salt  []byte `json:"salt,omitempty"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+func newSalt() []byte {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	return salt
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2_iter, 32)
+}
+
+// 从系统钥匙串读取密钥
+func loadKeyringKey() ([]byte, error) {
+	secret, err := keyring.Get(keyring_service, keyring_user)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(secret)
+}
+
+// 生成随机密钥并保存到系统钥匙串，已存在时直接使用
+func createKeyringKey() ([]byte, error) {
+	if key, err := loadKeyringKey(); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	if err := keyring.Set(keyring_service, keyring_user, base64.StdEncoding.EncodeToString(key)); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func deleteKeyringKey() error {
+	err := keyring.Delete(keyring_service, keyring_user)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func encryptHistoryData(data HistoryData, key []byte, salt []byte) (*EncryptedData, error) {
+	plain, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	return &EncryptedData{
+		Salt:  salt,
+		Nonce: nonce,
+		Data:  gcm.Seal(nil, nonce, plain, nil),
+	}, nil
+}
+
+func decryptHistoryData(encrypted *EncryptedData, key []byte) (HistoryData, error) {
+	data := HistoryData{}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return data, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return data, err
+	}
+	if len(encrypted.Nonce) != gcm.NonceSize() {
+		return data, errors.New("加密数据已损坏")
+	}
+	plain, err := gcm.Open(nil, encrypted.Nonce, encrypted.Data, nil)
+	if err != nil {
+		return data, errors.New("密码错误或加密数据已损坏")
+	}
+	err = json.Unmarshal(plain, &data)
+	return data, err
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 解析JSON，整数保持为整数，避免转换为YAML/TOML时变成浮点数
+func decodeJSON(text string) (any, error) {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("JSON之后还有多余的内容")
+	}
+	return normalizeNumbers(value), nil
+}
+
+func normalizeNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalizeNumbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+	}
+	return value
+}
+
+func validateJSON(text string) (string, error) {
+	if _, err := decodeJSON(text); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, column := offsetToLineColumn(text, int(syntaxErr.Offset))
+			return "", fmt.Errorf("第%d行第%d列: %v", line, column, err)
+		}
+		return "", err
+	}
+	return "JSON格式正确", nil
+}
+
+func offsetToLineColumn(text string, offset int) (int, int) {
+	offset = min(offset, len(text))
+	before := text[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := offset - strings.LastIndex(before, "\n")
+	return line, column
+}
+
+func jsonToYAML(text string) (string, error) {
+	value, err := decodeJSON(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func jsonToTOML(text string) (string, error) {
+	value, err := decodeJSON(text)
+	if err != nil {
+		return "", err
+	}
+	return encodeTOML(value)
+}
+
+func encodeTOML(value any) (string, error) {
+	if _, ok := value.(map[string]any); !ok {
+		return "", fmt.Errorf("TOML的顶层必须是对象")
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(value); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func decodeYAML(text string) (any, error) {
+	var value any
+	if err := yaml.Unmarshal([]byte(text), &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func decodeTOML(text string) (map[string]any, error) {
+	value := make(map[string]any)
+	if _, err := toml.Decode(text, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func toJSON(value any) (string, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func yamlToJSON(text string) (string, error) {
+	value, err := decodeYAML(text)
+	if err != nil {
+		return "", err
+	}
+	return toJSON(value)
+}
+
+func yamlToTOML(text string) (string, error) {
+	value, err := decodeYAML(text)
+	if err != nil {
+		return "", err
+	}
+	return encodeTOML(value)
+}
+
+func tomlToJSON(text string) (string, error) {
+	value, err := decodeTOML(text)
+	if err != nil {
+		return "", err
+	}
+	return toJSON(value)
+}
+
+func tomlToYAML(text string) (string, error) {
+	value, err := decodeTOML(text)
+	if err != nil {
+		return "", err
+	}
+	data, err := yaml.Marshal(value)
+	return string(data), err
+}
+
+var (
+	jsonPathToken = regexp.MustCompile(`^(?:\.\.|\.\*|\.([^.\[]+)|\[\s*'([^']*)'\s*\]|\[\s*"([^"]*)"\s*\]|\[\s*(-?\d+)\s*\]|\[\s*\*\s*\])`)
+	jsonPathField = regexp.MustCompile(`^([^.\[]+)`)
+)
+
+// 按JSONPath提取值，支持 $.a.b、$['a']、$[0]、$[-1]、$[*]、$.* 和 $..a
+func extractJSONPath(text string, path string) (string, error) {
+	value, err := decodeJSON(text)
+	if err != nil {
+		return "", err
+	}
+
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "$") {
+		return "", fmt.Errorf("JSONPath必须以$开头: %s", path)
+	}
+	path = path[1:]
+
+	current := []any{value}
+	for path != "" {
+		groups := jsonPathToken.FindStringSubmatch(path)
+		if groups == nil {
+			return "", fmt.Errorf("无法解析JSONPath: %s", path)
+		}
+		token := groups[0]
+		path = path[len(token):]
+
+		next := []any{}
+		switch {
+		case token == "..":
+			// 递归下降，后面必须跟字段名
+			groups = jsonPathField.FindStringSubmatch(path)
+			if groups == nil {
+				return "", fmt.Errorf("..后面必须是字段名")
+			}
+			path = path[len(groups[0]):]
+			for _, v := range current {
+				next = append(next, collectJSONField(v, groups[1])...)
+			}
+		case groups[1] != "" || groups[2] != "" || groups[3] != "" || strings.HasPrefix(token, "['") || strings.HasPrefix(token, `["`):
+			key := groups[1] + groups[2] + groups[3]
+			for _, v := range current {
+				if m, ok := v.(map[string]any); ok {
+					if field, ok := m[key]; ok {
+						next = append(next, field)
+					}
+				}
+			}
+		case groups[4] != "":
+			index, _ := strconv.Atoi(groups[4])
+			for _, v := range current {
+				if list, ok := v.([]any); ok {
+					i := Ifel(index < 0, len(list)+index, index)
+					if i >= 0 && i < len(list) {
+						next = append(next, list[i])
+					}
+				}
+			}
+		default:
+			for _, v := range current {
+				switch c := v.(type) {
+				case []any:
+					next = append(next, c...)
+				case map[string]any:
+					for _, key := range slices.Sorted(maps.Keys(c)) {
+						next = append(next, c[key])
+					}
+				}
+			}
+		}
+		current = next
+	}
+
+	switch len(current) {
+	case 0:
+		return "", fmt.Errorf("没有匹配的值")
+	case 1:
+		if s, ok := current[0].(string); ok {
+			return s, nil
+		}
+		return toJSON(current[0])
+	default:
+		return toJSON(current)
+	}
+}
+
+func collectJSONField(value any, key string) []any {
+	result := []any{}
+	switch v := value.(type) {
+	case map[string]any:
+		if field, ok := v[key]; ok {
+			result = append(result, field)
+		}
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			result = append(result, collectJSONField(v[k], key)...)
+		}
+	case []any:
+		for _, item := range v {
+			result = append(result, collectJSONField(item, key)...)
+		}
+	}
+	return result
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// 文本内容的类型
+type ContentKind string
+
+const (
+	ContentText      ContentKind = ""
+	ContentURL       ContentKind = "url"
+	ContentEmail     ContentKind = "email"
+	ContentPath      ContentKind = "path"
+	ContentJSON      ContentKind = "json"
+	ContentXML       ContentKind = "xml"
+	ContentYAML      ContentKind = "yaml"
+	ContentTOML      ContentKind = "toml"
+	ContentUUID      ContentKind = "uuid"
+	ContentPhone     ContentKind = "phone"
+	ContentIP        ContentKind = "ip"
+	ContentTimestamp ContentKind = "timestamp"
+	ContentCode      ContentKind = "code"
+	ContentColor     ContentKind = "color"
+)
+
+type ContentDetector struct {
+	Kind   ContentKind
+	Name   string
+	Icon   string
+	Detect func(text string) bool
+	// 复制转换后的内容
+	Actions []Transformer
+	// 返回用系统默认程序打开的目标，为空时不显示打开
+	Open func(text string) string
+}
+
+// 按顺序检测，先匹配的优先
+var content_detectors = []ContentDetector{}
+
+func RegisterContentDetector(detector ContentDetector) {
+	content_detectors = append(content_detectors, detector)
+}
+
+func detectContentKind(text string) ContentKind {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ContentText
+	}
+	for _, detector := range content_detectors {
+		if detector.Detect(text) {
+			return detector.Kind
+		}
+	}
+	return ContentText
+}
+
+func getContentDetector(kind ContentKind) (ContentDetector, bool) {
+	for _, detector := range content_detectors {
+		if detector.Kind == kind {
+			return detector, true
+		}
+	}
+	return ContentDetector{}, false
+}
+
+func contentKindIcon(kind ContentKind) string {
+	if detector, ok := getContentDetector(kind); ok {
+		return detector.Icon
+	}
+	return "📝"
+}
+
+var (
+	uuidPattern   = regexp.MustCompile(`(?i)^[\da-f]{8}-[\da-f]{4}-[\da-f]{4}-[\da-f]{4}-[\da-f]{12}$`)
+	emailPattern  = regexp.MustCompile(`^[\w.%+-]+@[\w-]+(\.[\w-]+)*\.[a-zA-Z]{2,}$`)
+	phonePattern  = regexp.MustCompile(`^\+?[\d\s()-]{7,20}$`)
+	digitPattern  = regexp.MustCompile(`\d`)
+	mobilePattern = regexp.MustCompile(`^1[3-9]\d{9}$`)
+	tomlPattern   = regexp.MustCompile(`(?m)^\s*(\[[\w.\-" ]+\]|[\w\-"]+\s*=\s*\S)`)
+	yamlPattern   = regexp.MustCompile(`(?m)^(\w[\w\- ]*:(\s|$)|- )`)
+	codePattern   = regexp.MustCompile(`(?m)(;\s*$|\{\s*$|^\s*\}|^\s*(func|def|class|import|package|return|const|let|var|public|private|#include)\b|=>|:=)`)
+)
+
+func isSingleLine(text string) bool {
+	return !strings.ContainsAny(text, "\r\n")
+}
+
+func init() {
+	RegisterContentDetector(ContentDetector{
+		Kind:   ContentUUID,
+		Name:   "UUID",
+		Icon:   "🆔",
+		Detect: uuidPattern.MatchString,
+		Actions: []Transformer{
+			{Name: "复制为大写", Apply: func(text string) (string, error) { return strings.ToUpper(text), nil }},
+			{Name: "复制为小写", Apply: func(text string) (string, error) { return strings.ToLower(text), nil }},
+			{Name: "复制无连字符", Apply: func(text string) (string, error) { return strings.ReplaceAll(text, "-", ""), nil }},
+		},
+	})
+	RegisterContentDetector(ContentDetector{
+		Kind: ContentIP,
+		Name: "IP地址",
+		Icon: "🌐",
+		Detect: func(text string) bool {
+			if host, _, err := net.SplitHostPort(text); err == nil {
+				text = host
+			}
+			return net.ParseIP(text) != nil
+		},
+		Actions: []Transformer{
+			{Name: "复制IP(去除端口)", Apply: func(text string) (string, error) {
+				if host, _, err := net.SplitHostPort(text); err == nil {
+					return host, nil
+				}
+				return text, nil
+			}},
+		},
+	})
+	RegisterContentDetector(ContentDetector{
+		Kind:   ContentEmail,
+		Name:   "邮箱",
+		Icon:   "📧",
+		Detect: emailPattern.MatchString,
+		Actions: []Transformer{
+			{Name: "复制域名", Apply: func(text string) (string, error) { return text[strings.LastIndex(text, "@")+1:], nil }},
+		},
+		Open: func(text string) string { return "mailto:" + text },
+	})
+	RegisterContentDetector(ContentDetector{
+		Kind: ContentURL,
+		Name: "链接",
+		Icon: "🔗",
+		Detect: func(text string) bool {
+			if !isSingleLine(text) || strings.ContainsAny(text, " \t") {
+				return false
+			}
+			u, err := url.Parse(text)
+			return err == nil && u.Host != "" && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "ftp")
+		},
+		Actions: []Transformer{
+			{Name: "复制域名", Apply: func(text string) (string, error) {
+				u, err := url.Parse(text)
+				if err != nil {
+					return "", err
+				}
+				return u.Hostname(), nil
+			}},
+			{Name: "复制去除参数的链接", Apply: func(text string) (string, error) {
+				u, err := url.Parse(text)
+				if err != nil {
+					return "", err
+				}
+				u.RawQuery, u.Fragment = "", ""
+				return u.String(), nil
+			}},
+		},
+		Open: func(text string) string { return text },
+	})
+	RegisterContentDetector(ContentDetector{
+		Kind:   ContentTimestamp,
+		Name:   "时间",
+		Icon:   "🕒",
+		Detect: func(text string) bool { _, ok := parseTimestamp(text); return ok },
+	})
+	RegisterContentDetector(ContentDetector{
+		Kind:   ContentColor,
+		Name:   "颜色",
+		Icon:   "🎨",
+		Detect: func(text string) bool { _, ok := parseColor(text); return ok },
+	})
+	RegisterContentDetector(ContentDetector{
+		Kind: ContentPhone,
+		Name: "电话号码",
+		Icon: "📞",
+		Detect: func(text string) bool {
+			if !phonePattern.MatchString(text) || len(digitPattern.FindAllString(text, -1)) < 7 {
+				return false
+			}
+			// 纯数字只识别手机号，避免把普通数字当成电话
+			return strings.HasPrefix(text, "+") || strings.ContainsAny(text, " ()-") || mobilePattern.MatchString(text)
+		},
+		Actions: []Transformer{
+			{Name: "复制纯数字", Apply: func(text string) (string, error) {
+				return strings.Join(digitPattern.FindAllString(text, -1), ""), nil
+			}},
+		},
+		Open: func(text string) string { return "tel:" + text },
+	})
+	RegisterContentDetector(ContentDetector{
+		Kind: ContentPath,
+		Name: "文件路径",
+		Icon: "📁",
+		Detect: func(text string) bool {
+			return isSingleLine(text) && (filepath.IsAbs(text) || strings.HasPrefix(text, "~/") || strings.HasPrefix(text, "/"))
+		},
+		Actions: []Transformer{
+			{Name: "复制文件名", Apply: func(text string) (string, error) { return filepath.Base(text), nil }},
+			{Name: "复制所在目录", Apply: func(text string) (string, error) { return filepath.Dir(text), nil }},
+		},
+		Open: func(text string) string { return filepath.Dir(text) },
+	})
+	RegisterContentDetector(ContentDetector{
+		Kind: ContentJSON,
+		Name: "JSON",
+		Icon: "🧾",
+		Detect: func(text string) bool {
+			return (strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[")) && json.Valid([]byte(text))
+		},
+	})
+	RegisterContentDetector(ContentDetector{
+		Kind:   ContentXML,
+		Name:   "XML",
+		Icon:   "📰",
+		Detect: isXML,
+	})
+	RegisterContentDetector(ContentDetector{
+		Kind: ContentTOML,
+		Name: "TOML",
+		Icon: "🧾",
+		Detect: func(text string) bool {
+			if !tomlPattern.MatchString(text) {
+				return false
+			}
+			value, err := decodeTOML(text)
+			return err == nil && len(value) > 0
+		},
+	})
+	RegisterContentDetector(ContentDetector{
+		Kind: ContentYAML,
+		Name: "YAML",
+		Icon: "🧾",
+		Detect: func(text string) bool {
+			// 只识别多行的映射或列表，避免把普通文本当成YAML
+			if isSingleLine(text) || !yamlPattern.MatchString(text) {
+				return false
+			}
+			value, err := decodeYAML(text)
+			if err != nil {
+				return false
+			}
+			switch value.(type) {
+			case map[string]any, []any:
+				return true
+			}
+			return false
+		},
+	})
+	RegisterContentDetector(ContentDetector{
+		Kind: ContentCode,
+		Name: "代码",
+		Icon: "💻",
+		Detect: func(text string) bool {
+			return !isSingleLine(text) && len(codePattern.FindAllString(text, 3)) >= 2
+		},
+	})
+}
+
+func isXML(text string) bool {
+	if !strings.HasPrefix(text, "<") || !strings.HasSuffix(text, ">") {
+		return false
+	}
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	elements := 0
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return elements > 0
+		}
+		if err != nil {
+			return false
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			elements++
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+// 其他平台点击托盘图标或按下快捷键时活动窗口不变，直接在点击时记录，不需要监听
+func watchForeground(callback func(pasteTarget)) (func(), error) {
+	return func() {}, nil
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"container/list"
 	"crypto/md5"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 )
@@ -12,8 +14,15 @@ type ItemType int
 const (
 	TypeText ItemType = iota
 	TypeImage
+	TypeFiles
 )
 
+var item_type_names = map[ItemType]string{
+	TypeText:  "text",
+	TypeImage: "image",
+	TypeFiles: "files",
+}
+
 type ItemFrom int
 
 const (
@@ -21,32 +30,67 @@ const (
 	FromRemote
 )
 
+// 重复记录的处理方式
+type DedupePolicy string
+
+const (
+	DedupeNone DedupePolicy = "none" // 允许重复
+	DedupeTop  DedupePolicy = "top"  // 只跳过与最新记录相同的条目
+	DedupeMove DedupePolicy = "move" // 已存在的条目移动到最前面
+)
+
+var dedupe_policies = []struct {
+	Policy DedupePolicy
+	Name   string
+}{
+	{DedupeNone, "允许重复"},
+	{DedupeTop, "跳过与最新记录相同的"},
+	{DedupeMove, "移动到最前面"},
+}
+
 type ClipItem struct {
 	Type     ItemType `json:"type"`
 	Content  []byte `json:"content"`
 	Hash     string `json:"hash"`
 	Time     time.Time `json:"time"`
 	From     ItemFrom `json:"from"`
+	Peer     string `json:"peer,omitempty"`
+	Ocr      string `json:"ocr,omitempty"`
+	Kind     ContentKind `json:"kind,omitempty"`
+	Pinned   bool `json:"pinned,omitempty"`
+	UseCount int `json:"use_count,omitempty"`
+	LastUsed time.Time `json:"last_used,omitzero"`
+	Sensitive bool `json:"sensitive,omitempty"`
 }
 
 func NewClipItem(itemType ItemType, content []byte) *ClipItem{
-	return &ClipItem{
+	item := &ClipItem{
 		Type:     itemType,
 		Content:  append([]byte{}, content...),
 		Hash:     fmt.Sprintf("%x", md5.Sum(content)),
 		Time:     time.Now(),
 		From:     FromLocal,
 	}
+	item.DetectKind()
+	return item
 }
 
 func NewClipItemFromRemote(itemType ItemType, content []byte) *ClipItem{
-	return &ClipItem{
+	item := &ClipItem{
 		Type:     itemType,
 		Content:  append([]byte{}, content...),
 		Hash:     fmt.Sprintf("%x", md5.Sum(content)),
 		Time:     time.Now(),
 		From:     FromRemote,
 	}
+	item.DetectKind()
+	return item
+}
+
+func (c *ClipItem) DetectKind() {
+	if c.Type == TypeText {
+		c.Kind = detectContentKind(string(c.Content))
+	}
 }
 
 func (c *ClipItem) CloneToRemote() *ClipItem{
@@ -56,6 +100,8 @@ func (c *ClipItem) CloneToRemote() *ClipItem{
 		Hash:     c.Hash,
 		Time:     c.Time,
 		From:     FromRemote,
+		Ocr:      c.Ocr,
+		Kind:     c.Kind,
 	}
 }
 
@@ -66,76 +112,445 @@ func (c *ClipItem) Clone() *ClipItem{
 		Hash:     c.Hash,
 		Time:     c.Time,
 		From:     c.From,
+		Peer:     c.Peer,
+		Ocr:      c.Ocr,
+		Kind:     c.Kind,
+		Pinned:   c.Pinned,
+		UseCount: c.UseCount,
+		LastUsed: c.LastUsed,
+		Sensitive: c.Sensitive,
 	}
 }
 
+// 历史记录按添加顺序保存在链表中，最新的条目在链表末尾
+// 条目和内容分别建立索引，删除、移动和查找相同内容都不需要遍历或移动其他条目
+// 对外的索引和列表都按从新到旧的顺序
 type History struct {
-	items   []*ClipItem
-	maxSize uint
-	mu      sync.RWMutex
+	items    *list.List                   // 元素为 *ClipItem
+	elements map[*ClipItem]*list.Element  // 条目 -> 链表元素
+	index    map[string][]*list.Element   // 内容 -> 相同内容的元素，按从旧到新的顺序
+	pinned   int
+	// 未固定条目的统计，用于判断是否超出保留策略
+	bytes    uint
+	images   uint
+	remote   uint
+	maxSize  uint
+	dedupe   DedupePolicy
+	retention RetentionPolicy
+	used     *ClipItem // 最近从菜单复制的条目，写入剪贴板后再次添加时不重复计数
+	mu       sync.RWMutex
 }
 
 func NewHistory(maxSize uint) *History {
 	return &History{
-		items:   []*ClipItem{},
-		maxSize: maxSize,
+		items:    list.New(),
+		elements: make(map[*ClipItem]*list.Element),
+		index:    make(map[string][]*list.Element),
+		maxSize:  maxSize,
+		dedupe:   DedupeTop,
+	}
+}
+
+func itemKey(item *ClipItem) string {
+	return fmt.Sprintf("%d:%s", item.Type, item.Hash)
+}
+
+func itemOf(e *list.Element) *ClipItem {
+	return e.Value.(*ClipItem)
+}
+
+// 更新未固定条目的统计，delta 为 1 或 -1
+func (h *History) count(item *ClipItem, delta int) {
+	if item.Pinned {
+		h.pinned += delta
+		return
+	}
+	h.bytes = uint(int(h.bytes) + delta*len(item.Content))
+	if item.Type == TypeImage {
+		h.images = uint(int(h.images) + delta)
+	}
+	if item.From == FromRemote {
+		h.remote = uint(int(h.remote) + delta)
+	}
+}
+
+// 在链表末尾添加条目并建立索引
+func (h *History) pushBack(item *ClipItem) {
+	e := h.items.PushBack(item)
+	h.elements[item] = e
+	key := itemKey(item)
+	h.index[key] = append(h.index[key], e)
+	h.count(item, 1)
+	global_search_index.Add(item)
+}
+
+// 删除链表中的元素和索引
+func (h *History) removeElement(e *list.Element) {
+	item := itemOf(e)
+	h.items.Remove(e)
+	delete(h.elements, item)
+	key := itemKey(item)
+	if elements := slices.DeleteFunc(h.index[key], func(it *list.Element) bool { return it == e }); len(elements) > 0 {
+		h.index[key] = elements
+	} else {
+		delete(h.index, key)
 	}
+	h.count(item, -1)
+	global_search_index.Remove(item)
 }
 
+// 按链表顺序重建内容索引
+func (h *History) reindex() {
+	h.index = make(map[string][]*list.Element)
+	for e := h.items.Front(); e != nil; e = e.Next() {
+		key := itemKey(itemOf(e))
+		h.index[key] = append(h.index[key], e)
+	}
+}
+
+// 查找最新的相同内容的条目
+func (h *History) findLatest(item *ClipItem) *list.Element {
+	elements := h.index[itemKey(item)]
+	if len(elements) == 0 {
+		return nil
+	}
+	return elements[len(elements)-1]
+}
+
+// 添加条目，返回是否改变了历史记录
 func (h *History) Add(item *ClipItem) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if len(h.items) > 0{
-		top := h.items[0]
-		if top != nil && top.Type == item.Type && top.Hash == item.Hash {
+	if _, ok := h.elements[item]; ok {
+		return false
+	}
+	used := h.used
+	h.used = nil
+
+	// 与最新记录相同时只更新时间，避免当前剪贴板内容被保留策略按时间删除
+	if h.dedupe == DedupeTop && h.items.Len() > 0{
+		top := itemOf(h.items.Back())
+		if top.Type == item.Type && top.Hash == item.Hash {
+			top.Time = item.Time
 			return false
 		}
 	}
 
-	// 允许重复，直接添加到最前面
-	h.items = append([]*ClipItem{item}, h.items...)
-	if (uint)(len(h.items)) > h.maxSize {
-		h.items = h.items[:h.maxSize]
+	latest := h.findLatest(item)
+
+	// 已存在的条目移动到最前面，更新时间和使用次数
+	// 已是最新的条目不需要移动，返回 false，避免重复共享
+	if h.dedupe == DedupeMove && latest != nil {
+		it := itemOf(latest)
+		it.Time = item.Time
+		// 从菜单复制时已经记录过使用
+		if it != used {
+			it.UseCount++
+			it.LastUsed = item.Time
+		}
+		it.Sensitive = it.Sensitive || item.Sensitive
+		item.Sensitive = it.Sensitive
+		if latest == h.items.Back() {
+			return false
+		}
+		h.items.MoveToBack(latest)
+		// 移动后是相同内容中最新的
+		key := itemKey(it)
+		elements := slices.DeleteFunc(h.index[key], func(e *list.Element) bool { return e == latest })
+		h.index[key] = append(elements, latest)
+		return true
+	}
+
+	// 新条目继承相同内容的使用记录和敏感标记
+	if latest != nil {
+		it := itemOf(latest)
+		item.UseCount = max(item.UseCount, it.UseCount)
+		item.Sensitive = item.Sensitive || it.Sensitive
+		if it.LastUsed.After(item.LastUsed) {
+			item.LastUsed = it.LastUsed
+		}
 	}
+
+	h.pushBack(item)
+	h.evict()
 	return true
 }
 
+// 记录一次从菜单复制条目
+func (h *History) MarkUsed(item *ClipItem) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	item.UseCount++
+	item.LastUsed = time.Now()
+	h.used = item
+}
+
+// 未固定的条目是否超出最大条数和保留策略的数量限制
+func (h *History) overLimits() (count, bytes, images, remote bool) {
+	p := h.retention
+	count = uint(h.items.Len()-h.pinned) > h.maxSize
+	bytes = p.MaxBytes > 0 && h.bytes > p.MaxBytes*1024*1024
+	images = p.MaxImages > 0 && h.images > p.MaxImages
+	remote = p.MaxRemote > 0 && h.remote > p.MaxRemote
+	return
+}
+
+func (h *History) expired(item *ClipItem, now time.Time) bool {
+	return expired(item.Time, now, h.retention.MaxAge) || (item.From == FromRemote && expired(item.Time, now, h.retention.RemoteMaxAge))
+}
+
+// 添加条目后从最早的记录开始删除超出限制的记录，遇到不需要删除的记录即停止
+// 固定的记录和最新的一条不会被删除，中间过期的记录由定时清理删除
+func (h *History) evict() {
+	now := time.Now()
+	newest := h.items.Back()
+	for e := h.items.Front(); e != nil && e != newest; {
+		next := e.Next()
+		item := itemOf(e)
+		count, bytes, images, remote := h.overLimits()
+		if !count && !bytes && !images && !remote && !h.expired(item, now) {
+			return
+		}
+		if !item.Pinned && (count || bytes || (images && item.Type == TypeImage) || (remote && item.From == FromRemote) || h.expired(item, now)) {
+			h.removeElement(e)
+		}
+		e = next
+	}
+}
+
+// 删除超出最大条数和保留策略的记录，固定的记录不计入条数也不会被删除，返回删除的条数
+func (h *History) trim() int {
+	// 没有保留策略且未超出最大条数时不需要遍历
+	if h.retention == (RetentionPolicy{}) && uint(h.items.Len()-h.pinned) <= h.maxSize {
+		return 0
+	}
+
+	// 从新到旧统计需要保留的条目
+	var count uint = 0
+	removed := 0
+	counter := newRetentionCounter(h.retention)
+	for e := h.items.Back(); e != nil; {
+		prev := e.Prev()
+		if item := itemOf(e); !item.Pinned {
+			if count < h.maxSize && counter.keep(item, count == 0) {
+				count++
+			} else {
+				h.removeElement(e)
+				removed++
+			}
+		}
+		e = prev
+	}
+	return removed
+}
+
+// 按保留策略清理过期的记录，返回删除的条数
+func (h *History) Sweep() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.trim()
+}
+
+func (h *History) SetRetention(policy RetentionPolicy) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.retention = policy
+	h.trim()
+}
+
+func (h *History) Retention() RetentionPolicy {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.retention
+}
+
+// 加载保存的条目，items 按从新到旧的顺序，排在已有条目之后
+func (h *History) Load(items []*ClipItem) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	front := h.items.Front()
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if _, ok := h.elements[item]; ok {
+			continue
+		}
+		if front != nil {
+			h.elements[item] = h.items.InsertBefore(item, front)
+		} else {
+			h.elements[item] = h.items.PushBack(item)
+		}
+		h.count(item, 1)
+		global_search_index.Add(item)
+	}
+	h.reindex()
+	h.trim()
+}
+
+func (h *History) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.items.Len()
+}
+
+// 按从新到旧的顺序返回所有条目
 func (h *History) GetAll() []*ClipItem {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	result := make([]*ClipItem, len(h.items))
-	copy(result, h.items)
+	result := make([]*ClipItem, 0, h.items.Len())
+	for e := h.items.Back(); e != nil; e = e.Prev() {
+		result = append(result, itemOf(e))
+	}
 	return result
 }
 
+// 按从新到旧的顺序分页返回未固定的条目，同时返回未固定条目的总数
+func (h *History) GetPage(offset int, limit int) ([]*ClipItem, int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result := []*ClipItem{}
+	skipped := 0
+	for e := h.items.Back(); e != nil && len(result) < limit; e = e.Prev() {
+		item := itemOf(e)
+		if item.Pinned {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		result = append(result, item)
+	}
+	return result, h.items.Len() - h.pinned
+}
+
 func (h *History) GetTop() *ClipItem {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	if len(h.items) > 0 {
-		return h.items[0]
+	if e := h.items.Back(); e != nil {
+		return itemOf(e)
 	}
 	return nil
 }
 
+func (h *History) GetPinned() []*ClipItem {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result := []*ClipItem{}
+	if h.pinned == 0 {
+		return result
+	}
+	for e := h.items.Back(); e != nil; e = e.Prev() {
+		if item := itemOf(e); item.Pinned {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func (h *History) SetPinned(item *ClipItem, pinned bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if item.Pinned == pinned {
+		return
+	}
+	if _, ok := h.elements[item]; !ok {
+		item.Pinned = pinned
+		return
+	}
+	h.count(item, -1)
+	item.Pinned = pinned
+	h.count(item, 1)
+	h.trim()
+}
+
+func (h *History) SetSensitive(item *ClipItem, sensitive bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	item.Sensitive = sensitive
+}
+
+// 删除早于指定时间的敏感内容，固定的记录除外，返回删除的条数
+func (h *History) RemoveExpiredSensitive(before time.Time) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	removed := 0
+	for e := h.items.Front(); e != nil; {
+		next := e.Next()
+		if item := itemOf(e); item.Sensitive && !item.Pinned && item.Time.Before(before) {
+			h.removeElement(e)
+			removed++
+		}
+		e = next
+	}
+	return removed
+}
+
+func (h *History) Contains(item *ClipItem) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.index[itemKey(item)]) > 0
+}
+
+// 清空历史记录，保留固定的记录
 func (h *History) Clear() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	h.items = []*ClipItem{}
+	for e := h.items.Front(); e != nil; {
+		next := e.Next()
+		if !itemOf(e).Pinned {
+			h.removeElement(e)
+		}
+		e = next
+	}
 }
 
+// 删除指定索引的条目，索引按从新到旧的顺序
 func (h *History) Delete(index int) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if index < 0 || index >= len(h.items) {
+	if index < 0 || index >= h.items.Len() {
 		return
 	}
 	global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("正在删除历史记录中索引为%d的记录...", index)}
-	h.items = append(h.items[:index], h.items[index+1:]...)
+	e := h.items.Back()
+	for i := 0; i < index; i++ {
+		e = e.Prev()
+	}
+	h.removeElement(e)
+}
+
+func (h *History) Remove(item *ClipItem) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if e, ok := h.elements[item]; ok {
+		global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("正在删除历史记录: %s", formatLogItem(item))}
+		h.removeElement(e)
+	}
+}
+
+func (h *History) SetDedupePolicy(policy DedupePolicy) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.dedupe = policy
 }
 
 func (h *History) SetMaxSize(max uint) {
@@ -144,9 +559,9 @@ func (h *History) SetMaxSize(max uint) {
 
 	h.maxSize = max
 
-	if max < (uint)(len(h.items)) {
+	if max < uint(h.items.Len()) {
 		global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("历史记录超过新设置的最大值%d，正在删除多余的记录...", max)}
-		h.items = h.items[:max]
+		h.trim()
 	}
 }
 
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"container/list"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// 历史记录删除条目时会写日志，测试中直接丢弃
+	go func() {
+		for range global_log_channel {
+		}
+	}()
+	os.Exit(m.Run())
+}
+
+func newTestHistory(maxSize uint, dedupe DedupePolicy) *History {
+	global_search_index = NewSearchIndex()
+	h := NewHistory(maxSize)
+	h.SetDedupePolicy(dedupe)
+	return h
+}
+
+func addTexts(h *History, texts ...string) []*ClipItem {
+	items := make([]*ClipItem, len(texts))
+	for i, text := range texts {
+		items[i] = NewClipItem(TypeText, []byte(text))
+		h.Add(items[i])
+	}
+	return items
+}
+
+func historyTexts(h *History) []string {
+	texts := []string{}
+	for _, item := range h.GetAll() {
+		texts = append(texts, string(item.Content))
+	}
+	return texts
+}
+
+// 检查链表、条目索引、内容索引、统计和搜索索引是否一致
+func checkHistory(t *testing.T, h *History) {
+	t.Helper()
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.elements) != h.items.Len() {
+		t.Fatalf("条目索引有%d条，链表有%d条", len(h.elements), h.items.Len())
+	}
+	index := make(map[string][]*list.Element)
+	pinned := 0
+	var bytes uint = 0
+	for e := h.items.Front(); e != nil; e = e.Next() {
+		item := itemOf(e)
+		if h.elements[item] != e {
+			t.Fatalf("条目 %q 的索引不是链表中的元素", item.Content)
+		}
+		index[itemKey(item)] = append(index[itemKey(item)], e)
+		if item.Pinned {
+			pinned++
+		} else {
+			bytes += uint(len(item.Content))
+		}
+		if !global_search_index.Contains(item) {
+			t.Fatalf("搜索索引中没有 %q", item.Content)
+		}
+	}
+	if len(index) != len(h.index) {
+		t.Fatalf("内容索引有%d项，应为%d项", len(h.index), len(index))
+	}
+	for key, elements := range index {
+		if !slices.Equal(elements, h.index[key]) {
+			t.Fatalf("内容 %s 的索引顺序与链表不一致", key)
+		}
+	}
+	if pinned != h.pinned || bytes != h.bytes {
+		t.Fatalf("统计为固定%d条、%d字节，应为固定%d条、%d字节", h.pinned, h.bytes, pinned, bytes)
+	}
+}
+
+func TestHistoryDedupe(t *testing.T) {
+	tests := []struct {
+		name   string
+		dedupe DedupePolicy
+		texts  []string
+		want   []string
+		added  []bool
+	}{
+		{"允许重复", DedupeNone, []string{"a", "b", "b", "a"}, []string{"a", "b", "b", "a"}, []bool{true, true, true, true}},
+		{"跳过与最新相同", DedupeTop, []string{"a", "b", "b", "a"}, []string{"a", "b", "a"}, []bool{true, true, false, true}},
+		{"移动到最前面", DedupeMove, []string{"a", "b", "c", "a"}, []string{"a", "c", "b"}, []bool{true, true, true, true}},
+		{"已是最新时不移动", DedupeMove, []string{"a", "b", "b"}, []string{"b", "a"}, []bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHistory(100, tt.dedupe)
+			for i, text := range tt.texts {
+				if added := h.Add(NewClipItem(TypeText, []byte(text))); added != tt.added[i] {
+					t.Fatalf("第%d次添加 %q 返回 %v，应为 %v", i, text, added, tt.added[i])
+				}
+			}
+			if got := historyTexts(h); !slices.Equal(got, tt.want) {
+				t.Fatalf("历史记录为 %v，应为 %v", got, tt.want)
+			}
+			checkHistory(t, h)
+		})
+	}
+}
+
+func TestHistoryMoveUseCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		used  bool // 从菜单复制后再次添加
+		texts []string
+		want  int
+	}{
+		{"移动时计数", false, []string{"a", "b", "a"}, 1},
+		{"已是最新时计数", false, []string{"a", "a"}, 1},
+		{"从菜单复制只计数一次", true, []string{"a", "b", "a"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHistory(100, DedupeMove)
+			items := addTexts(h, tt.texts[:len(tt.texts)-1]...)
+			if tt.used {
+				h.MarkUsed(items[0])
+			}
+			h.Add(NewClipItem(TypeText, []byte(tt.texts[len(tt.texts)-1])))
+			if items[0].UseCount != tt.want {
+				t.Fatalf("使用次数为%d，应为%d", items[0].UseCount, tt.want)
+			}
+			checkHistory(t, h)
+		})
+	}
+}
+
+func TestHistoryPinned(t *testing.T) {
+	h := newTestHistory(2, DedupeTop)
+	items := addTexts(h, "a", "b")
+	h.SetPinned(items[0], true)
+	addTexts(h, "c", "d", "e")
+
+	// 固定的记录不计入条数，也不会被删除
+	if got, want := historyTexts(h), []string{"e", "d", "a"}; !slices.Equal(got, want) {
+		t.Fatalf("历史记录为 %v，应为 %v", got, want)
+	}
+	h.Clear()
+	if got, want := historyTexts(h), []string{"a"}; !slices.Equal(got, want) {
+		t.Fatalf("清空后为 %v，应为 %v", got, want)
+	}
+	checkHistory(t, h)
+}
+
+func TestHistoryIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(h *History, items []*ClipItem)
+		want  []string
+	}{
+		{"删除条目", func(h *History, items []*ClipItem) {
+			h.Remove(items[0])
+		}, []string{"c", "b", "b"}},
+		{"删除重复内容中的一条", func(h *History, items []*ClipItem) {
+			h.Remove(items[2])
+		}, []string{"c", "b", "a"}},
+		{"按索引删除", func(h *History, items []*ClipItem) {
+			h.Delete(1)
+		}, []string{"c", "b", "a"}},
+		{"重复内容移动到最前面", func(h *History, items []*ClipItem) {
+			h.SetDedupePolicy(DedupeMove)
+			h.Add(NewClipItem(TypeText, []byte("a")))
+		}, []string{"a", "c", "b", "b"}},
+		{"减小最大条数", func(h *History, items []*ClipItem) {
+			h.SetMaxSize(2)
+		}, []string{"c", "b"}},
+		{"超出最大条数", func(h *History, items []*ClipItem) {
+			h.SetMaxSize(4)
+			addTexts(h, "d", "e")
+		}, []string{"e", "d", "c", "b"}},
+		{"加载保存的记录", func(h *History, items []*ClipItem) {
+			h.Load([]*ClipItem{NewClipItem(TypeText, []byte("x")), NewClipItem(TypeText, []byte("b"))})
+		}, []string{"c", "b", "b", "a", "x", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHistory(100, DedupeNone)
+			items := addTexts(h, "a", "b", "b", "c")
+			tt.apply(h, items)
+			if got := historyTexts(h); !slices.Equal(got, tt.want) {
+				t.Fatalf("历史记录为 %v，应为 %v", got, tt.want)
+			}
+			checkHistory(t, h)
+		})
+	}
+}
+
+func TestHistoryRemoveFromSearchIndex(t *testing.T) {
+	h := newTestHistory(100, DedupeNone)
+	items := addTexts(h, "a", "b", "b")
+
+	// 相同内容还有其他条目时保留在搜索索引中
+	h.Remove(items[1])
+	if !global_search_index.Contains(items[1]) {
+		t.Fatal("仍在历史记录中的内容被移出了搜索索引")
+	}
+	h.Remove(items[2])
+	if global_search_index.Contains(items[2]) {
+		t.Fatal("已删除的内容仍在搜索索引中")
+	}
+	checkHistory(t, h)
+}
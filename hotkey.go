@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 修饰键
+const (
+	ModCtrl uint = 1 << iota
+	ModShift
+	ModAlt
+	ModSuper
+)
+
+// 全局快捷键，如 ctrl+shift+v
+type Hotkey struct {
+	Mods uint
+	Key  string
+	Text string
+}
+
+// 快捷键设置，为空时不注册
+type HotkeyConfig struct {
+	Menu     string `json:"menu"`      // 打开历史记录
+	PasteNth string `json:"paste_nth"` // 修饰键，加数字1-9复制第N条之前的记录
+	Cycle    string `json:"cycle"`     // 依次复制更早的记录
+}
+
+var hotkey_modifiers = map[string]uint{
+	"ctrl":    ModCtrl,
+	"control": ModCtrl,
+	"shift":   ModShift,
+	"alt":     ModAlt,
+	"option":  ModAlt,
+	"super":   ModSuper,
+	"win":     ModSuper,
+	"cmd":     ModSuper,
+	"meta":    ModSuper,
+}
+
+// 解析快捷键，修饰键和按键用+连接，不区分大小写
+func ParseHotkey(text string) (Hotkey, error) {
+	hotkey := Hotkey{Text: text}
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(text)), "+")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if mod, ok := hotkey_modifiers[part]; ok {
+			if i == len(parts)-1 {
+				return hotkey, fmt.Errorf("快捷键缺少按键: %s", text)
+			}
+			hotkey.Mods |= mod
+			continue
+		}
+		if i != len(parts)-1 || part == "" {
+			return hotkey, fmt.Errorf("无法解析快捷键: %s", text)
+		}
+		hotkey.Key = part
+	}
+	if hotkey.Mods == 0 {
+		return hotkey, fmt.Errorf("快捷键至少需要一个修饰键: %s", text)
+	}
+	return hotkey, nil
+}
+
+// 按快捷键设置生成需要注册的快捷键，actions 与快捷键一一对应
+func buildHotkeys(config HotkeyConfig) ([]Hotkey, []string, error) {
+	hotkeys := []Hotkey{}
+	actions := []string{}
+	add := func(text string, action string) error {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		hotkey, err := ParseHotkey(text)
+		if err != nil {
+			return err
+		}
+		hotkeys = append(hotkeys, hotkey)
+		actions = append(actions, action)
+		return nil
+	}
+
+	if err := add(config.Menu, "menu"); err != nil {
+		return nil, nil, err
+	}
+	if err := add(config.Cycle, "cycle"); err != nil {
+		return nil, nil, err
+	}
+	if strings.TrimSpace(config.PasteNth) != "" {
+		for n := 1; n <= 9; n++ {
+			if err := add(fmt.Sprintf("%s+%d", config.PasteNth, n), fmt.Sprintf("paste:%d", n)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return hotkeys, actions, nil
+}
@@ -0,0 +1,242 @@
+package main
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+#include <sys/select.h>
+#include <X11/Xlib.h>
+
+// 运行时加载 libX11，与剪贴板库保持一致，不需要链接 X11
+static void* x11_handle = NULL;
+static Display* (*pXOpenDisplay)(const char*);
+static int (*pXCloseDisplay)(Display*);
+static Window (*pXDefaultRootWindow)(Display*);
+static KeySym (*pXStringToKeysym)(const char*);
+static KeyCode (*pXKeysymToKeycode)(Display*, KeySym);
+static int (*pXGrabKey)(Display*, int, unsigned int, Window, Bool, int, int);
+static int (*pXUngrabKey)(Display*, int, unsigned int, Window);
+static int (*pXPending)(Display*);
+static int (*pXNextEvent)(Display*, XEvent*);
+static int (*pXConnectionNumber)(Display*);
+static int (*pXSync)(Display*, Bool);
+static XErrorHandler (*pXSetErrorHandler)(XErrorHandler);
+
+static Display* hotkey_open() {
+	if (x11_handle == NULL) {
+		x11_handle = dlopen("libX11.so", RTLD_LAZY);
+		if (x11_handle == NULL) {
+			x11_handle = dlopen("libX11.so.6", RTLD_LAZY);
+		}
+		if (x11_handle == NULL) {
+			return NULL;
+		}
+		pXOpenDisplay = dlsym(x11_handle, "XOpenDisplay");
+		pXCloseDisplay = dlsym(x11_handle, "XCloseDisplay");
+		pXDefaultRootWindow = dlsym(x11_handle, "XDefaultRootWindow");
+		pXStringToKeysym = dlsym(x11_handle, "XStringToKeysym");
+		pXKeysymToKeycode = dlsym(x11_handle, "XKeysymToKeycode");
+		pXGrabKey = dlsym(x11_handle, "XGrabKey");
+		pXUngrabKey = dlsym(x11_handle, "XUngrabKey");
+		pXPending = dlsym(x11_handle, "XPending");
+		pXNextEvent = dlsym(x11_handle, "XNextEvent");
+		pXConnectionNumber = dlsym(x11_handle, "XConnectionNumber");
+		pXSync = dlsym(x11_handle, "XSync");
+		pXSetErrorHandler = dlsym(x11_handle, "XSetErrorHandler");
+	}
+	return pXOpenDisplay(NULL);
+}
+
+static void hotkey_close(Display* d) {
+	pXCloseDisplay(d);
+}
+
+static int grab_failed = 0;
+static int hotkey_error_handler(Display* d, XErrorEvent* e) {
+	grab_failed = 1;
+	return 0;
+}
+
+// 同时注册 NumLock、CapsLock 的组合，返回按键码，按键不存在返回 -1，已被占用返回 -2
+static int hotkey_grab(Display* d, const char* key, unsigned int mods) {
+	KeySym sym = pXStringToKeysym(key);
+	if (sym == NoSymbol) {
+		return -1;
+	}
+	KeyCode code = pXKeysymToKeycode(d, sym);
+	if (code == 0) {
+		return -1;
+	}
+
+	Window root = pXDefaultRootWindow(d);
+	unsigned int extra[] = {0, LockMask, Mod2Mask, LockMask | Mod2Mask};
+	grab_failed = 0;
+	XErrorHandler old = pXSetErrorHandler(hotkey_error_handler);
+	for (int i = 0; i < 4; i++) {
+		pXGrabKey(d, code, mods | extra[i], root, False, GrabModeAsync, GrabModeAsync);
+	}
+	pXSync(d, False);
+	pXSetErrorHandler(old);
+	return grab_failed ? -2 : code;
+}
+
+static void hotkey_ungrab(Display* d, int code, unsigned int mods) {
+	Window root = pXDefaultRootWindow(d);
+	unsigned int extra[] = {0, LockMask, Mod2Mask, LockMask | Mod2Mask};
+	for (int i = 0; i < 4; i++) {
+		pXUngrabKey(d, code, mods | extra[i], root);
+	}
+	pXSync(d, False);
+}
+
+// 等待按键，超时返回 0
+static int hotkey_wait(Display* d, int timeout_ms, unsigned int* keycode, unsigned int* state) {
+	if (!pXPending(d)) {
+		int fd = pXConnectionNumber(d);
+		fd_set fds;
+		FD_ZERO(&fds);
+		FD_SET(fd, &fds);
+		struct timeval tv = {timeout_ms / 1000, (timeout_ms % 1000) * 1000};
+		if (select(fd + 1, &fds, NULL, NULL, &tv) <= 0) {
+			return 0;
+		}
+	}
+	while (pXPending(d)) {
+		XEvent ev;
+		pXNextEvent(d, &ev);
+		if (ev.type == KeyPress) {
+			*keycode = ev.xkey.keycode;
+			*state = ev.xkey.state;
+			return 1;
+		}
+	}
+	return 0;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+func x11Mods(mods uint) C.uint {
+	var result C.uint = 0
+	if mods&ModCtrl != 0 {
+		result |= C.ControlMask
+	}
+	if mods&ModShift != 0 {
+		result |= C.ShiftMask
+	}
+	if mods&ModAlt != 0 {
+		result |= C.Mod1Mask
+	}
+	if mods&ModSuper != 0 {
+		result |= C.Mod4Mask
+	}
+	return result
+}
+
+var x11_key_names = map[string]string{
+	"enter":    "Return",
+	"esc":      "Escape",
+	"pageup":   "Prior",
+	"pagedown": "Next",
+	"left":     "Left",
+	"right":    "Right",
+	"up":       "Up",
+	"down":     "Down",
+	"home":     "Home",
+	"end":      "End",
+	"insert":   "Insert",
+	"delete":   "Delete",
+	"tab":      "Tab",
+}
+
+func x11KeyName(key string) string {
+	if name, ok := x11_key_names[key]; ok {
+		return name
+	}
+	if len(key) > 1 && key[0] == 'f' {
+		return "F" + key[1:]
+	}
+	return key
+}
+
+// 通过 X11 注册全局快捷键，Wayland 下只对 XWayland 窗口有效
+// 返回的停止函数等待快捷键注销后才返回，之后可以立即重新注册相同的快捷键
+func registerHotkeys(hotkeys []Hotkey, callback func(index int)) (func(), error) {
+	result := make(chan error, 1)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		display := C.hotkey_open()
+		if display == nil {
+			result <- errors.New("无法连接到 X11 显示服务")
+			return
+		}
+		defer C.hotkey_close(display)
+
+		codes := make([]C.int, len(hotkeys))
+		for i, hotkey := range hotkeys {
+			key := C.CString(x11KeyName(hotkey.Key))
+			codes[i] = C.hotkey_grab(display, key, x11Mods(hotkey.Mods))
+			C.free(unsafe.Pointer(key))
+			if codes[i] < 0 {
+				for j := 0; j < i; j++ {
+					C.hotkey_ungrab(display, codes[j], x11Mods(hotkeys[j].Mods))
+				}
+				result <- fmt.Errorf("注册快捷键%s失败: %s", hotkey.Text, Ifel(codes[i] == -1, "按键不存在", "已被其他程序占用"))
+				return
+			}
+		}
+		result <- nil
+
+		const modMask = C.ControlMask | C.ShiftMask | C.Mod1Mask | C.Mod4Mask
+		for {
+			select {
+			case <-stop:
+				for i, hotkey := range hotkeys {
+					C.hotkey_ungrab(display, codes[i], x11Mods(hotkey.Mods))
+				}
+				return
+			default:
+			}
+
+			var keycode, state C.uint
+			if C.hotkey_wait(display, 200, &keycode, &state) == 0 {
+				continue
+			}
+			for i, hotkey := range hotkeys {
+				if C.int(keycode) == codes[i] && state&modMask == x11Mods(hotkey.Mods) {
+					go callback(i)
+					break
+				}
+			}
+		}
+	}()
+
+	if err := <-result; err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+		})
+	}, nil
+}
+
+// Linux 托盘菜单只能通过点击图标打开
+func showTrayMenu() error {
+	return errors.New("当前平台不支持通过快捷键显示托盘菜单")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// 在 Xvfb 虚拟显示中启动测试，没有安装 Xvfb 时跳过
+func startXvfb(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("Xvfb"); err != nil {
+		t.Skip("未安装 Xvfb")
+	}
+
+	display := fmt.Sprintf(":%d", 90+os.Getpid()%100)
+	cmd := exec.Command("Xvfb", display, "-nolisten", "tcp")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("启动 Xvfb 失败: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	socket := fmt.Sprintf("/tmp/.X11-unix/X%s", display[1:])
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("等待 Xvfb 启动超时")
+		}
+	}
+	t.Setenv("DISPLAY", display)
+}
+
+func TestRegisterHotkeysX11(t *testing.T) {
+	startXvfb(t)
+
+	hotkeys := []Hotkey{{Mods: ModCtrl | ModAlt, Key: "1", Text: "ctrl+alt+1"}}
+	pressed := make(chan int, 1)
+	stop, err := registerHotkeys(hotkeys, func(index int) { pressed <- index })
+	if err != nil {
+		t.Fatalf("注册快捷键失败: %v", err)
+	}
+
+	// 另一个连接注册相同的组合会失败
+	if other, err := registerHotkeys(hotkeys, func(int) {}); err == nil {
+		other()
+		t.Fatal("重复注册的快捷键没有报错")
+	}
+
+	if _, err := exec.LookPath("xdotool"); err == nil {
+		if err := exec.Command("xdotool", "key", "ctrl+alt+1").Run(); err != nil {
+			t.Fatalf("发送按键失败: %v", err)
+		}
+		select {
+		case index := <-pressed:
+			if index != 0 {
+				t.Errorf("触发的快捷键为 %d", index)
+			}
+		case <-time.After(2 * time.Second):
+			t.Error("没有收到快捷键")
+		}
+	}
+
+	// 停止后立即重新注册相同的快捷键
+	stop()
+	stop, err = registerHotkeys(hotkeys, func(int) {})
+	if err != nil {
+		t.Fatalf("停止后重新注册失败: %v", err)
+	}
+	stop()
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !windows
+
+package main
+
+import "errors"
+
+// 其他平台暂不支持全局快捷键
+func registerHotkeys(hotkeys []Hotkey, callback func(index int)) (func(), error) {
+	return nil, errors.New("当前平台不支持全局快捷键")
+}
+
+func showTrayMenu() error {
+	return errors.New("当前平台不支持通过快捷键显示托盘菜单")
+}
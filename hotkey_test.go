@@ -0,0 +1,61 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseHotkey(t *testing.T) {
+	tests := []struct {
+		text string
+		mods uint
+		key  string
+		ok   bool
+	}{
+		{"ctrl+shift+v", ModCtrl | ModShift, "v", true},
+		{" Ctrl + Alt + F12 ", ModCtrl | ModAlt, "f12", true},
+		{"cmd+option+space", ModSuper | ModAlt, "space", true},
+		{"win+1", ModSuper, "1", true},
+		{"v", 0, "", false},
+		{"ctrl+shift", 0, "", false},
+		{"ctrl+a+b", 0, "", false},
+		{"ctrl+", 0, "", false},
+		{"", 0, "", false},
+	}
+	for _, test := range tests {
+		hotkey, err := ParseHotkey(test.text)
+		if (err == nil) != test.ok {
+			t.Errorf("ParseHotkey(%q) error = %v, want ok = %v", test.text, err, test.ok)
+			continue
+		}
+		if test.ok && (hotkey.Mods != test.mods || hotkey.Key != test.key) {
+			t.Errorf("ParseHotkey(%q) = %+v, want mods %d key %q", test.text, hotkey, test.mods, test.key)
+		}
+	}
+}
+
+func TestBuildHotkeys(t *testing.T) {
+	hotkeys, actions, err := buildHotkeys(HotkeyConfig{Menu: "ctrl+shift+v", PasteNth: "ctrl+alt", Cycle: "ctrl+shift+c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"menu", "cycle", "paste:1", "paste:2", "paste:3", "paste:4", "paste:5", "paste:6", "paste:7", "paste:8", "paste:9"}
+	if !slices.Equal(actions, want) {
+		t.Fatalf("actions = %v, want %v", actions, want)
+	}
+	if len(hotkeys) != len(actions) {
+		t.Fatalf("%d hotkeys for %d actions", len(hotkeys), len(actions))
+	}
+	if hotkeys[2].Mods != ModCtrl|ModAlt || hotkeys[2].Key != "1" || hotkeys[10].Key != "9" {
+		t.Errorf("paste hotkeys = %+v, %+v", hotkeys[2], hotkeys[10])
+	}
+
+	hotkeys, actions, err = buildHotkeys(HotkeyConfig{})
+	if err != nil || len(hotkeys) != 0 || len(actions) != 0 {
+		t.Errorf("empty config = %v, %v, %v", hotkeys, actions, err)
+	}
+
+	if _, _, err := buildHotkeys(HotkeyConfig{Cycle: "shift"}); err == nil {
+		t.Error("invalid hotkey accepted")
+	}
+}
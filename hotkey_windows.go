@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32                 = syscall.NewLazyDLL("user32.dll")
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procRegisterHotKey     = user32.NewProc("RegisterHotKey")
+	procUnregisterHotKey   = user32.NewProc("UnregisterHotKey")
+	procGetMessageW        = user32.NewProc("GetMessageW")
+	procPostThreadMessageW = user32.NewProc("PostThreadMessageW")
+	procGetCurrentThreadId = kernel32.NewProc("GetCurrentThreadId")
+	procFindWindowExW      = user32.NewProc("FindWindowExW")
+	procPostMessageW       = user32.NewProc("PostMessageW")
+)
+
+const (
+	win_mod_alt      = 0x0001
+	win_mod_control  = 0x0002
+	win_mod_shift    = 0x0004
+	win_mod_win      = 0x0008
+	win_mod_norepeat = 0x4000
+	win_wm_hotkey    = 0x0312
+	win_wm_quit      = 0x0012
+	// 托盘库使用的窗口类和图标消息，发送左键抬起消息由托盘线程处理点击
+	win_tray_class   = "SystrayClass"
+	win_tray_message = 0x0400 + 1
+	win_wm_lbuttonup = 0x0202
+)
+
+type winMsg struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	x, y    int32
+}
+
+var win_key_codes = map[string]uintptr{
+	"space":    0x20,
+	"enter":    0x0D,
+	"tab":      0x09,
+	"esc":      0x1B,
+	"pageup":   0x21,
+	"pagedown": 0x22,
+	"end":      0x23,
+	"home":     0x24,
+	"left":     0x25,
+	"up":       0x26,
+	"right":    0x27,
+	"down":     0x28,
+	"insert":   0x2D,
+	"delete":   0x2E,
+}
+
+func winKeyCode(key string) (uintptr, bool) {
+	if code, ok := win_key_codes[key]; ok {
+		return code, true
+	}
+	// 字母的虚拟键码为大写字母，数字与字符相同
+	if len(key) == 1 && key[0] >= 'a' && key[0] <= 'z' {
+		return uintptr(key[0] - 'a' + 'A'), true
+	}
+	if len(key) == 1 && key[0] >= '0' && key[0] <= '9' {
+		return uintptr(key[0]), true
+	}
+	var n int
+	if _, err := fmt.Sscanf(key, "f%d", &n); err == nil && n >= 1 && n <= 24 {
+		return uintptr(0x70 + n - 1), true
+	}
+	return 0, false
+}
+
+func winMods(mods uint) uintptr {
+	var result uintptr = win_mod_norepeat
+	if mods&ModCtrl != 0 {
+		result |= win_mod_control
+	}
+	if mods&ModShift != 0 {
+		result |= win_mod_shift
+	}
+	if mods&ModAlt != 0 {
+		result |= win_mod_alt
+	}
+	if mods&ModSuper != 0 {
+		result |= win_mod_win
+	}
+	return result
+}
+
+// 通过 RegisterHotKey 注册全局快捷键，消息循环运行在固定的系统线程上
+// 返回的停止函数等待快捷键注销后才返回，之后可以立即重新注册相同的快捷键
+func registerHotkeys(hotkeys []Hotkey, callback func(index int)) (func(), error) {
+	result := make(chan error, 1)
+	threadId := make(chan uintptr, 1)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		tid, _, _ := procGetCurrentThreadId.Call()
+		for i, hotkey := range hotkeys {
+			code, ok := winKeyCode(hotkey.Key)
+			if !ok {
+				result <- fmt.Errorf("注册快捷键%s失败: 按键不存在", hotkey.Text)
+				return
+			}
+			if r, _, err := procRegisterHotKey.Call(0, uintptr(i+1), winMods(hotkey.Mods), code); r == 0 {
+				for j := 0; j < i; j++ {
+					procUnregisterHotKey.Call(0, uintptr(j+1))
+				}
+				result <- fmt.Errorf("注册快捷键%s失败: %v", hotkey.Text, err)
+				return
+			}
+		}
+		threadId <- tid
+		result <- nil
+
+		var msg winMsg
+		for {
+			r, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+			if int32(r) <= 0 {
+				break
+			}
+			if msg.message == win_wm_hotkey && msg.wParam >= 1 && int(msg.wParam) <= len(hotkeys) {
+				go callback(int(msg.wParam) - 1)
+			}
+		}
+		for i := range hotkeys {
+			procUnregisterHotKey.Call(0, uintptr(i+1))
+		}
+	}()
+
+	if err := <-result; err != nil {
+		return nil, err
+	}
+	tid := <-threadId
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			procPostThreadMessageW.Call(tid, win_wm_quit, 0, 0)
+			<-done
+		})
+	}, nil
+}
+
+// 在托盘线程中显示托盘菜单，与点击托盘图标相同
+func showTrayMenu() error {
+	class, err := syscall.UTF16PtrFromString(win_tray_class)
+	if err != nil {
+		return err
+	}
+	var hwnd uintptr
+	for {
+		hwnd, _, _ = procFindWindowExW.Call(0, hwnd, uintptr(unsafe.Pointer(class)), 0)
+		if hwnd == 0 {
+			return errors.New("找不到托盘窗口")
+		}
+		var pid uint32
+		procGetWindowThreadProcessId.Call(hwnd, uintptr(unsafe.Pointer(&pid)))
+		if int(pid) == os.Getpid() {
+			break
+		}
+	}
+	if r, _, err := procPostMessageW.Call(hwnd, win_tray_message, 0, win_wm_lbuttonup); r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 默认忽略的密码管理器
+var default_ignore_apps = []string{"keepassxc", "keepass", "1password", "bitwarden", "lastpass", "enpass"}
+
+var (
+	ignore_regexp_cache = make(map[string]*regexp.Regexp)
+	ignore_regexp_mu    sync.Mutex
+)
+
+func compileIgnorePattern(pattern string) (*regexp.Regexp, error) {
+	ignore_regexp_mu.Lock()
+	defer ignore_regexp_mu.Unlock()
+
+	if re, ok := ignore_regexp_cache[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	ignore_regexp_cache[pattern] = re
+	return re, nil
+}
+
+// 检查条目是否需要忽略，返回忽略的原因
+func shouldIgnore(item *ClipItem, apps []string, patterns []string) (string, bool) {
+	if item.Type != TypeImage {
+		text := string(item.Content)
+		for _, pattern := range patterns {
+			re, err := compileIgnorePattern(pattern)
+			if err != nil {
+				continue
+			}
+			if re.MatchString(text) {
+				return fmt.Sprintf("匹配忽略规则 %s", pattern), true
+			}
+		}
+	}
+
+	if len(apps) == 0 {
+		return "", false
+	}
+	for _, owner := range clipboardOwner() {
+		for _, app := range apps {
+			if strings.EqualFold(owner, app) {
+				return fmt.Sprintf("来自忽略的应用 %s", owner), true
+			}
+		}
+	}
+	return "", false
+}
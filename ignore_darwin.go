@@ -0,0 +1,41 @@
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework AppKit
+#import <AppKit/AppKit.h>
+#include <stdlib.h>
+
+// 返回当前活动应用的名称和可执行文件名，以换行分隔，由调用方释放
+static char *frontmost_application(void) {
+	@autoreleasepool {
+		NSRunningApplication *app = [[NSWorkspace sharedWorkspace] frontmostApplication];
+		if (app == nil) {
+			return strdup("");
+		}
+		NSString *name = app.localizedName ?: @"";
+		NSString *executable = app.executableURL.lastPathComponent ?: @"";
+		return strdup([[NSString stringWithFormat:@"%@\n%@", name, executable] UTF8String]);
+	}
+}
+*/
+import "C"
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// macOS 没有剪贴板所有者，使用当前活动的应用代替，后台应用写入的剪贴板内容无法识别
+func clipboardOwner() []string {
+	text := C.frontmost_application()
+	defer C.free(unsafe.Pointer(text))
+
+	apps := []string{}
+	for _, app := range strings.Split(C.GoString(text), "\n") {
+		if app != "" {
+			apps = append(apps, app)
+		}
+	}
+	return apps
+}
@@ -0,0 +1,143 @@
+package main
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <string.h>
+#include <X11/Xlib.h>
+#include <X11/Xatom.h>
+#include <X11/Xutil.h>
+
+// 运行时加载 libX11，连接保持打开，避免每次剪贴板变化都重新连接
+static void* owner_x11 = NULL;
+static Display* owner_display = NULL;
+static Display* (*pXOpenDisplay)(const char*);
+static Atom (*pXInternAtom)(Display*, const char*, Bool);
+static Window (*pXGetSelectionOwner)(Display*, Atom);
+static Status (*pXGetClassHint)(Display*, Window, XClassHint*);
+static int (*pXGetWindowProperty)(Display*, Window, Atom, long, long, Bool, Atom, Atom*, int*, unsigned long*, unsigned long*, unsigned char**);
+static int (*pXFree)(void*);
+static int (*pXSync)(Display*, Bool);
+static XErrorHandler (*pXSetErrorHandler)(XErrorHandler);
+
+static int owner_open() {
+	if (owner_x11 == NULL) {
+		owner_x11 = dlopen("libX11.so", RTLD_LAZY);
+		if (owner_x11 == NULL) {
+			owner_x11 = dlopen("libX11.so.6", RTLD_LAZY);
+		}
+		if (owner_x11 == NULL) {
+			return 0;
+		}
+		pXOpenDisplay = dlsym(owner_x11, "XOpenDisplay");
+		pXInternAtom = dlsym(owner_x11, "XInternAtom");
+		pXGetSelectionOwner = dlsym(owner_x11, "XGetSelectionOwner");
+		pXGetClassHint = dlsym(owner_x11, "XGetClassHint");
+		pXGetWindowProperty = dlsym(owner_x11, "XGetWindowProperty");
+		pXFree = dlsym(owner_x11, "XFree");
+		pXSync = dlsym(owner_x11, "XSync");
+		pXSetErrorHandler = dlsym(owner_x11, "XSetErrorHandler");
+	}
+	if (owner_display == NULL) {
+		owner_display = pXOpenDisplay(NULL);
+	}
+	return owner_display != NULL;
+}
+
+// 所有者窗口可能随时关闭，忽略 BadWindow 等错误
+static int owner_error_handler(Display* d, XErrorEvent* e) {
+	return 0;
+}
+
+static unsigned long owner_window_property(Window w, const char* name, Atom type) {
+	Atom prop = pXInternAtom(owner_display, name, True);
+	if (prop == None) {
+		return 0;
+	}
+	Atom actual;
+	int format;
+	unsigned long count, after;
+	unsigned char* data = NULL;
+	if (pXGetWindowProperty(owner_display, w, prop, 0, 1, False, type, &actual, &format, &count, &after, &data) != Success || data == NULL) {
+		return 0;
+	}
+	unsigned long value = count > 0 ? *(unsigned long*)data : 0;
+	pXFree(data);
+	return value;
+}
+
+static void owner_class(Window w, char* name, char* class, int size) {
+	XClassHint hint = {0};
+	if (!pXGetClassHint(owner_display, w, &hint)) {
+		return;
+	}
+	if (hint.res_name != NULL) {
+		strncpy(name, hint.res_name, size - 1);
+		pXFree(hint.res_name);
+	}
+	if (hint.res_class != NULL) {
+		strncpy(class, hint.res_class, size - 1);
+		pXFree(hint.res_class);
+	}
+}
+
+// 查询剪贴板所有者窗口的类名和进程号，所有者没有时读取所属应用的主窗口，没有所有者返回 0
+static unsigned long owner_query(char* name, char* class, int size, unsigned long* pid) {
+	XErrorHandler old = pXSetErrorHandler(owner_error_handler);
+	Window owner = pXGetSelectionOwner(owner_display, pXInternAtom(owner_display, "CLIPBOARD", False));
+	if (owner != None) {
+		owner_class(owner, name, class, size);
+		*pid = owner_window_property(owner, "_NET_WM_PID", XA_CARDINAL);
+		Window leader = owner_window_property(owner, "WM_CLIENT_LEADER", XA_WINDOW);
+		if (leader != None && leader != owner) {
+			if (name[0] == 0 && class[0] == 0) {
+				owner_class(leader, name, class, size);
+			}
+			if (*pid == 0) {
+				*pid = owner_window_property(leader, "_NET_WM_PID", XA_CARDINAL);
+			}
+		}
+	}
+	pXSync(owner_display, False);
+	pXSetErrorHandler(old);
+	return owner;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+var owner_mu sync.Mutex
+
+// 获取剪贴板所有者所属的应用，返回窗口类名和进程名，Wayland 原生应用无法获取
+func clipboardOwner() []string {
+	owner_mu.Lock()
+	defer owner_mu.Unlock()
+
+	if C.owner_open() == 0 {
+		return nil
+	}
+	var name, class [256]C.char
+	var pid C.ulong
+	if C.owner_query(&name[0], &class[0], C.int(len(name)), &pid) == 0 {
+		return nil
+	}
+
+	apps := []string{}
+	for _, app := range []string{C.GoString(&name[0]), C.GoString(&class[0])} {
+		if app != "" {
+			apps = append(apps, app)
+		}
+	}
+	if pid != 0 {
+		if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+			apps = append(apps, strings.TrimSpace(string(comm)))
+		}
+	}
+	return apps
+}
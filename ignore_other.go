@@ -0,0 +1,8 @@
+//go:build !linux && !windows && !darwin
+
+package main
+
+// 不支持的平台无法获取剪贴板所有者
+func clipboardOwner() []string {
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procGetClipboardOwner          = user32.NewProc("GetClipboardOwner")
+	procQueryFullProcessImageNameW = kernel32.NewProc("QueryFullProcessImageNameW")
+)
+
+const process_query_limited_information = 0x1000
+
+// 获取剪贴板所有者所属的应用，返回进程名和窗口类名，没有所有者窗口时返回空
+func clipboardOwner() []string {
+	hwnd, _, _ := procGetClipboardOwner.Call()
+	if hwnd == 0 {
+		return nil
+	}
+
+	apps := []string{}
+	var pid uint32
+	procGetWindowThreadProcessId.Call(hwnd, uintptr(unsafe.Pointer(&pid)))
+	if process, err := syscall.OpenProcess(process_query_limited_information, false, pid); err == nil {
+		var path [syscall.MAX_PATH]uint16
+		size := uint32(len(path))
+		if ok, _, _ := procQueryFullProcessImageNameW.Call(uintptr(process), 0, uintptr(unsafe.Pointer(&path[0])), uintptr(unsafe.Pointer(&size))); ok != 0 {
+			name := filepath.Base(syscall.UTF16ToString(path[:size]))
+			apps = append(apps, strings.TrimSuffix(name, filepath.Ext(name)))
+		}
+		syscall.CloseHandle(process)
+	}
+
+	var class [256]uint16
+	procGetClassNameW.Call(hwnd, uintptr(unsafe.Pointer(&class[0])), uintptr(len(class)))
+	if name := syscall.UTF16ToString(class[:]); name != "" {
+		apps = append(apps, name)
+	}
+	return apps
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// 缩略图边长
+const const_thumbnail_size = 32
+
+// 最多缓存的缩略图数量，超过时删除最早生成的
+const const_thumbnail_cache_size = 300
+
+type ImageInfo struct {
+	Width  int
+	Height int
+	Format string
+	Size   int
+}
+
+func getImageInfo(data []byte) (ImageInfo, bool) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ImageInfo{Size: len(data)}, false
+	}
+	return ImageInfo{
+		Width:  config.Width,
+		Height: config.Height,
+		Format: strings.ToUpper(format),
+		Size:   len(data),
+	}, true
+}
+
+func formatSize(size int) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(size)/1024/1024)
+	case size >= 1024:
+		return fmt.Sprintf("%dKB", size/1024)
+	default:
+		return fmt.Sprintf("%dB", size)
+	}
+}
+
+// 缩放图片，缩小时使用双线性插值
+func resizeImage(src image.Image, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.BiLinear.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// 转换为 RGBA，便于直接访问像素
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok {
+		return rgba
+	}
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// 保持比例缩放到不超过 size×size
+func makeThumbnail(data []byte, size int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	b := src.Bounds()
+	width, height := size, size
+	if b.Dx() > b.Dy() {
+		height = max(b.Dy()*size/b.Dx(), 1)
+	} else {
+		width = max(b.Dx()*size/b.Dy(), 1)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, resizeImage(src, width, height)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 缩略图缓存，在后台生成，避免阻塞菜单
+type ThumbnailCache struct {
+	thumbs map[string][]byte
+	order  []string // 按生成顺序的哈希，用于删除最早的缩略图
+	queue  chan *ClipItem
+	mu     sync.Mutex
+}
+
+var global_thumbnail_cache = NewThumbnailCache()
+
+func NewThumbnailCache() *ThumbnailCache {
+	c := &ThumbnailCache{
+		thumbs: make(map[string][]byte),
+		queue:  make(chan *ClipItem, 100),
+	}
+	go c.run()
+	return c
+}
+
+// 返回已生成的缩略图，未生成时加入队列
+func (c *ThumbnailCache) Get(item *ClipItem) ([]byte, bool) {
+	c.mu.Lock()
+	thumb, ok := c.thumbs[item.Hash]
+	c.mu.Unlock()
+	if ok {
+		return thumb, thumb != nil
+	}
+
+	select {
+	case c.queue <- item:
+	default:
+	}
+	return nil, false
+}
+
+func (c *ThumbnailCache) run() {
+	for item := range c.queue {
+		c.mu.Lock()
+		_, ok := c.thumbs[item.Hash]
+		c.mu.Unlock()
+		if ok {
+			continue
+		}
+
+		thumb, err := makeThumbnail(item.Content, const_thumbnail_size)
+		if err != nil {
+			global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("生成缩略图失败: %v", err)}
+		}
+
+		c.mu.Lock()
+		c.thumbs[item.Hash] = thumb
+		c.order = append(c.order, item.Hash)
+		if len(c.order) > const_thumbnail_cache_size {
+			delete(c.thumbs, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.mu.Unlock()
+	}
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 按宽度等比缩放
+func resizeImageWidth(data []byte, width int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	if b.Dx() <= width {
+		return nil, fmt.Errorf("图片宽度%d不大于%d", b.Dx(), width)
+	}
+	return encodePNG(resizeImage(src, width, max(b.Dy()*width/b.Dx(), 1)))
+}
+
+func grayscaleImage(data []byte) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	rgba := toRGBA(src)
+	dst := image.NewGray(rgba.Bounds())
+	// 与 color.GrayModel 相同的亮度系数
+	for i, j := 0, 0; i < len(rgba.Pix); i, j = i+4, j+1 {
+		r, g, b := uint32(rgba.Pix[i]), uint32(rgba.Pix[i+1]), uint32(rgba.Pix[i+2])
+		dst.Pix[j] = uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 16)
+	}
+	return encodePNG(dst)
+}
+
+// 重新编码为PNG，解码时会丢弃文本、EXIF、色彩配置等附加信息
+func stripImageMetadata(data []byte) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return encodePNG(src)
+}
+
+func encodeJPEG(data []byte, quality int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 编码为无损 WebP
+func encodeWebP(data []byte) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, src, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 写入剪贴板时转换的图片，记录转换后的哈希和原图
+type convertedImage struct {
+	hash    string
+	content []byte
+}
+
+var global_converted_image atomic.Pointer[convertedImage]
+
+// 剪贴板中的图片只能是PNG，其他格式的图片写入时转换为PNG，并记录原图
+func clipboardImageData(item *ClipItem) []byte {
+	if bytes.HasPrefix(item.Content, []byte("\x89PNG\r\n\x1a\n")) {
+		return item.Content
+	}
+	src, _, err := image.Decode(bytes.NewReader(item.Content))
+	if err != nil {
+		global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("转换图片失败: %v", err)}
+		return item.Content
+	}
+	data, err := encodePNG(src)
+	if err != nil {
+		global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("转换图片失败: %v", err)}
+		return item.Content
+	}
+	global_converted_image.Store(&convertedImage{hash: fmt.Sprintf("%x", md5.Sum(data)), content: item.Content})
+	return data
+}
+
+// 监听到写入时转换的图片时使用原图，历史记录中保留JPEG、WebP等原格式
+func restoreConvertedImage(item *ClipItem) *ClipItem {
+	if converted := global_converted_image.Load(); converted != nil && converted.hash == item.Hash {
+		return NewClipItem(TypeImage, converted.content)
+	}
+	return item
+}
+
+func imageDataURI(data []byte) string {
+	mime := http.DetectContentType(data)
+	return fmt.Sprintf("data:%s;base64,%s", mime, base64.StdEncoding.EncodeToString(data))
+}
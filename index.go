@@ -0,0 +1,257 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 超过该长度的文本不建立倒排索引，搜索时总是逐条匹配
+const const_search_index_max_runes = 8000
+
+// 删除的文本超过该数量且多于保留的文本时重建倒排索引
+const const_search_index_compact = 1024
+
+// 搜索索引，按内容哈希保存转为小写的文本，并建立单字和三字的倒排索引，历史记录和分组共用
+// 历史记录和分组添加、删除条目时同步更新，相同的内容只索引一次
+// 删除的文本只从文本表中移除，倒排索引中的编号在查询时跳过，积累较多时统一重建
+type SearchIndex struct {
+	docs   map[string]*searchDoc // 内容哈希 -> 文本
+	live   map[uint32]*searchDoc // 编号 -> 文本，只包含已建立倒排索引的文本
+	large  map[*searchDoc]bool   // 过长未建立倒排索引的文本
+	runes  map[rune][]uint32     // 单字 -> 包含该字的文本编号，按编号排序
+	grams  map[uint64][]uint32   // 连续三字 -> 包含的文本编号，按编号排序
+	nextID uint32
+	dead   int
+	mu     sync.RWMutex
+}
+
+type searchDoc struct {
+	id   uint32
+	hash string
+	text string
+	refs int
+}
+
+var global_search_index = NewSearchIndex()
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		docs:  make(map[string]*searchDoc),
+		live:  make(map[uint32]*searchDoc),
+		large: make(map[*searchDoc]bool),
+		runes: make(map[rune][]uint32),
+		grams: make(map[uint64][]uint32),
+	}
+}
+
+func gramKey(runes []rune) uint64 {
+	return uint64(runes[0])<<42 | uint64(runes[1])<<21 | uint64(runes[2])
+}
+
+// 条目加入历史记录或分组时调用，相同内容增加引用计数
+func (s *SearchIndex) Add(item *ClipItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if doc, ok := s.docs[item.Hash]; ok {
+		doc.refs++
+		return
+	}
+	doc := &searchDoc{hash: item.Hash, refs: 1}
+	s.docs[item.Hash] = doc
+	s.index(doc, strings.ToLower(searchableText(item)))
+}
+
+// 条目从历史记录或分组中删除时调用，没有引用时删除文本
+func (s *SearchIndex) Remove(item *ClipItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.docs[item.Hash]
+	if !ok {
+		return
+	}
+	doc.refs--
+	if doc.refs > 0 {
+		return
+	}
+	delete(s.docs, item.Hash)
+	s.retire(doc)
+}
+
+// 图片识别出文字后更新索引
+func (s *SearchIndex) SetText(hash string, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.docs[hash]
+	text = strings.ToLower(text)
+	if !ok || doc.text == text {
+		return
+	}
+	s.retire(doc)
+	s.index(doc, text)
+}
+
+// 分配新的编号并建立倒排索引
+func (s *SearchIndex) index(doc *searchDoc, text string) {
+	doc.text = text
+	runes := []rune(text)
+	if len(runes) > const_search_index_max_runes {
+		s.large[doc] = true
+		return
+	}
+
+	s.nextID++
+	doc.id = s.nextID
+	s.live[doc.id] = doc
+	seen := make(map[rune]bool)
+	for _, r := range runes {
+		if !seen[r] {
+			seen[r] = true
+			s.runes[r] = append(s.runes[r], doc.id)
+		}
+	}
+	grams := make(map[uint64]bool)
+	for i := 0; i+3 <= len(runes); i++ {
+		key := gramKey(runes[i : i+3])
+		if !grams[key] {
+			grams[key] = true
+			s.grams[key] = append(s.grams[key], doc.id)
+		}
+	}
+}
+
+// 文本失效，倒排索引中的编号留到重建时删除
+func (s *SearchIndex) retire(doc *searchDoc) {
+	if s.large[doc] {
+		delete(s.large, doc)
+		return
+	}
+	delete(s.live, doc.id)
+	s.dead++
+	if s.dead > const_search_index_compact && s.dead > len(s.live) {
+		s.compact()
+	}
+}
+
+// 只保留有效的编号重建倒排索引
+func (s *SearchIndex) compact() {
+	keep := func(ids []uint32) []uint32 {
+		return slices.DeleteFunc(ids, func(id uint32) bool { return s.live[id] == nil })
+	}
+	for r, ids := range s.runes {
+		if ids = keep(ids); len(ids) > 0 {
+			s.runes[r] = slices.Clip(ids)
+		} else {
+			delete(s.runes, r)
+		}
+	}
+	for key, ids := range s.grams {
+		if ids = keep(ids); len(ids) > 0 {
+			s.grams[key] = slices.Clip(ids)
+		} else {
+			delete(s.grams, key)
+		}
+	}
+	s.dead = 0
+}
+
+// 是否包含该内容，未索引的条目搜索时需要逐条匹配
+func (s *SearchIndex) Contains(item *ClipItem) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.docs[item.Hash]
+	return ok
+}
+
+// 转为小写的文本，用于不区分大小写的关键词匹配，未索引的条目直接转换
+func (s *SearchIndex) Lower(item *ClipItem) string {
+	s.mu.RLock()
+	doc, ok := s.docs[item.Hash]
+	text := ""
+	if ok {
+		text = doc.text
+	}
+	s.mu.RUnlock()
+	if ok {
+		return text
+	}
+	return strings.ToLower(searchableText(item))
+}
+
+// 返回可能匹配所有关键词的内容哈希，没有关键词时返回 nil，表示需要逐条匹配
+// 普通关键词可能模糊匹配，按包含关键词中的每个字筛选，精确匹配的短语按连续的三字筛选
+func (s *SearchIndex) Candidates(q *SearchQuery) map[string]bool {
+	if len(q.terms) == 0 {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []uint32
+	for i, term := range q.terms {
+		matched := s.termCandidates(term)
+		if i == 0 {
+			ids = matched
+		} else {
+			ids = slices.DeleteFunc(ids, func(id uint32) bool {
+				_, found := slices.BinarySearch(matched, id)
+				return !found
+			})
+		}
+		if len(ids) == 0 {
+			break
+		}
+	}
+
+	candidates := make(map[string]bool, len(ids)+len(s.large))
+	for _, id := range ids {
+		candidates[s.live[id].hash] = true
+	}
+	for doc := range s.large {
+		candidates[doc.hash] = true
+	}
+	return candidates
+}
+
+// 关键词的候选编号，按编号排序，只包含有效的文本
+func (s *SearchIndex) termCandidates(term searchTerm) []uint32 {
+	runes := []rune(term.text)
+	lists := [][]uint32{}
+	if term.exact && len(runes) >= 3 {
+		for i := 0; i+3 <= len(runes); i++ {
+			lists = append(lists, s.grams[gramKey(runes[i:i+3])])
+		}
+	} else {
+		for _, r := range runes {
+			lists = append(lists, s.runes[r])
+		}
+	}
+	if len(lists) == 0 {
+		return nil
+	}
+
+	// 从最短的列表开始，在其他列表中二分查找
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	result := []uint32{}
+	for _, id := range lists[0] {
+		if s.live[id] == nil {
+			continue
+		}
+		found := true
+		for _, list := range lists[1:] {
+			if _, found = slices.BinarySearch(list, id); !found {
+				break
+			}
+		}
+		if found {
+			result = append(result, id)
+		}
+	}
+	return result
+}
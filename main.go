@@ -2,12 +2,18 @@ package main
 
 import (
 	"bytes"
-	"crypto/md5"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/energye/systray"
@@ -43,14 +49,25 @@ var (
 	global_show_menu_state = Click
 	global_search_enable = false
 	global_search_text string = ""
+	global_search_query *SearchQuery = nil
 	global_history_share_server *ShareServer = nil
 	global_history_share_clients map[string]*ShareClient = make(map[string]*ShareClient)
 	global_log_channel = make(chan LogEntry, 5)
+	global_locked = false
+	global_encrypt_key []byte = nil
+	global_encrypt_salt []byte = nil
+	global_pause = NewPauseState()
+	global_control_server *ControlServer = nil
+	global_stop_hotkeys func() = nil
+	global_stop_foreground_watch func() = nil
 )
 
 // 全局常量
 const (
-	const_max_history uint = 300
+	// 可设置的最大历史记录条数
+	const_max_history uint = 100000
+	// 每个分组最多保存的条数
+	const_max_group_history uint = 1000
 )
 
 // 全局配置
@@ -58,7 +75,33 @@ var (
 	config_history_max uint = const_max_history
 	config_single_delete = false
 	config_auto_recognize_color = false
+	config_auto_recognize_time = false
+	config_recognize_named_color = false
 	config_save_log_to_local = false
+	config_share_download_dir = ""
+	config_saved_filters = []SavedFilter{}
+	config_ocr_enable = false
+	config_ocr_engine = "tesseract"
+	config_ocr_command = "tesseract"
+	config_ocr_language = "chi_sim+eng"
+	config_menu_thumbnail = true
+	config_snippets = []Snippet{}
+	config_dedupe_policy = DedupeTop
+	config_history_order = OrderTime
+	config_sensitive_detect = true
+	config_sensitive_expire uint = 0
+	config_encrypt_enable = false
+	config_encrypt_key_source = KeySourceKeyring
+	config_ignore_apps = default_ignore_apps
+	config_ignore_patterns = []string{}
+	config_control_api = true
+	config_retention = RetentionPolicy{}
+	config_menu_page_size uint = 30
+	config_menu_recent_count uint = 10
+	config_hotkey_enable = false
+	config_hotkeys = HotkeyConfig{Menu: "ctrl+shift+v", PasteNth: "ctrl+alt", Cycle: "ctrl+alt+v"}
+	config_picker_font = ""
+	config_auto_paste = AutoPasteConfig{}
 )
 
 
@@ -68,14 +111,29 @@ func formatMenuItem(item *ClipItem) string {
 
 	switch item.Type {
 	case TypeText:
-		prefix = "📝"
+		prefix = contentKindIcon(item.Kind)
 		text = truncateString(text, 40)
+		if item.Sensitive {
+			prefix = "🔒"
+			text = maskSensitive(string(item.Content))
+		}
 
 	case TypeImage:
 		prefix = "🖼️"
-		text = fmt.Sprintf("图片 [%s]", fmt.Sprintf("%x", md5.Sum(item.Content))[:8])
+		hash := item.Hash[:min(8, len(item.Hash))]
+		if info, ok := getImageInfo(item.Content); ok {
+			text = fmt.Sprintf("图片 %d×%d %s %s [%s]", info.Width, info.Height, info.Format, formatSize(info.Size), hash)
+		} else {
+			text = fmt.Sprintf("图片 [%s]", hash)
+		}
+
+	case TypeFiles:
+		prefix = "📎"
+		paths := strings.Split(text, "\n")
+		text = fmt.Sprintf("文件 [%d] %s", len(paths), truncateString(filepath.Base(paths[0]), 30))
 	}
 
+	prefix = Ifel(item.Pinned, "📌", "") + prefix
 	t := fmt.Sprintf("%s [%s]%s%s", prefix, item.Time.Format("15:04"), Ifel(item.From == FromRemote, " [R] ", ""), text)
 
 	// 安全检查：确保返回值不为空
@@ -89,9 +147,16 @@ func formatMenuItem(item *ClipItem) string {
 func formatMenuItemTooltip(item *ClipItem) string {
 	switch item.Type {
 	case TypeText:
-		return string(item.Content)
+		return Ifel(item.Sensitive, "敏感内容，已隐藏", string(item.Content))
 	case TypeImage:
-		return "图片"
+		tooltip := "图片"
+		if info, ok := getImageInfo(item.Content); ok {
+			tooltip = fmt.Sprintf("图片\n尺寸: %d×%d\n格式: %s\n大小: %s", info.Width, info.Height, info.Format, formatSize(info.Size))
+		}
+		text := ocrText(item)
+		return tooltip + Ifel(text != "", "\n\n" + text, "")
+	case TypeFiles:
+		return string(item.Content)
 	default:
 		return ""
 	}
@@ -119,8 +184,13 @@ func startMonitor() (chan *ClipItem, chan *ClipItem, error) {
 
 	go func() {
 		for item := range writer {
-			global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("写入剪贴板: %s", formatMenuItem(item))}
-			clipboard.Write(Ifel(item.Type == TypeImage, clipboard.FmtImage, clipboard.FmtText), item.Content)
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("写入剪贴板: %s", formatLogItem(item))}
+			if item.Type == TypeImage {
+				clipboard.Write(clipboard.FmtImage, clipboardImageData(item))
+			} else {
+				clipboard.Write(clipboard.FmtText, item.Content)
+			}
+			global_write_waiter.Done(item)
 		}
 	}()
 
@@ -129,17 +199,52 @@ func startMonitor() (chan *ClipItem, chan *ClipItem, error) {
 		ticker := time.NewTicker(200 * time.Millisecond)
 		defer ticker.Stop()
 
+		// 只在剪贴板内容变化时生成新条目，剪贴板中不再有该格式时清空，再次复制相同内容仍会记录
+		var lastText, lastImage []byte
+		// 密码、忽略的应用和内容不会进入历史记录、分组和局域网共享
+		emit := func(item *ClipItem) {
+			if takePassphrase(item) {
+				return
+			}
+			if global_pause.Paused() {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: "已暂停记录，丢弃剪贴板内容"}
+				return
+			}
+			if reason, ok := shouldIgnore(item, config_ignore_apps, config_ignore_patterns); ok {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("忽略剪贴板内容: %s", reason)}
+				return
+			}
+			reader <- item
+		}
+
 		for range ticker.C {
 			// 监听文本
 			text := clipboard.Read(clipboard.FmtText)
-			if len(text) > 0 {
-				reader <- NewClipItem(TypeText, text)
+			if len(text) == 0 {
+				lastText = nil
+			} else if !bytes.Equal(text, lastText) {
+				lastText = text
+				if paths, ok := parseFileList(string(text)); ok {
+					emit(NewClipItem(TypeFiles, []byte(strings.Join(paths, "\n"))))
+				} else {
+					item := NewClipItem(TypeText, text)
+					if config_sensitive_detect {
+						item.DetectSensitive()
+						if !item.Sensitive && clipboardConcealed() {
+							item.Sensitive = true
+						}
+					}
+					emit(item)
+				}
 			}
 
 			// 监听图片
 			image := clipboard.Read(clipboard.FmtImage)
-			if len(image) > 0 {
-				reader <- NewClipItem(TypeImage, image)
+			if len(image) == 0 {
+				lastImage = nil
+			} else if !bytes.Equal(image, lastImage) {
+				lastImage = image
+				emit(restoreConvertedImage(NewClipItem(TypeImage, image)))
 			}
 		}
 	}()
@@ -148,6 +253,10 @@ func startMonitor() (chan *ClipItem, chan *ClipItem, error) {
 }
 
 func main() {
+	if runCli(os.Args[1:]) {
+		return
+	}
+
 	logToLocal := func () func()  {
 		buffer := &bytes.Buffer{}
 
@@ -173,6 +282,57 @@ func main() {
 	groups := make(map[string]*Group)
 	groupNames := []string{}
 
+	// 尚未解密的历史记录，锁定时不为空
+	var lockedData *EncryptedData = nil
+
+	// 加载历史记录和分组，锁定期间新增的条目保留在前面
+	loadHistoryData := func(data HistoryData) {
+		// 旧版本保存的条目没有内容类型，重新识别
+		data.History = loadStoredImages(data.History)
+		for _, item := range data.History {
+			item.DetectKind()
+		}
+		history.Load(data.History)
+		for name, groupData := range data.Groups {
+			if _, ok := groups[name]; !ok {
+				groups[name] = NewGroup(name, groupData.Active, const_max_group_history)
+				groups[name].History.SetDedupePolicy(config_dedupe_policy)
+			}
+			groups[name].History.SetRetention(groupData.Retention)
+			groupData.History = loadStoredImages(groupData.History)
+			for _, item := range groupData.History {
+				item.DetectKind()
+			}
+			groups[name].History.Load(groupData.History)
+		}
+		for _, name := range data.GroupNames {
+			if !slices.Contains(groupNames, name) {
+				groupNames = append(groupNames, name)
+			}
+		}
+	}
+
+	// 使用密钥解锁历史记录
+	unlockHistory := func(key []byte) error {
+		if lockedData != nil {
+			data, err := decryptHistoryData(lockedData, key)
+			if err != nil {
+				return err
+			}
+			loadHistoryData(data)
+			lockedData = nil
+			if config_ocr_enable {
+				startOcr(history, groups)
+			}
+		} else if subtle.ConstantTimeCompare(key, global_encrypt_key) != 1 {
+			return errors.New("密码错误")
+		}
+		global_encrypt_key = key
+		global_locked = false
+		global_log_channel <- LogEntry{Kind: KindInfo, Content: "历史记录已解锁"}
+		return nil
+	}
+
 	cacheToLocal := func() func()  {
 		global_log_channel <- LogEntry{Kind: KindInfo, Content: "正在加载配置和历史记录..."}
 
@@ -185,24 +345,70 @@ func main() {
 		config_history_max = localConfig.HistoryMax
 		config_single_delete = localConfig.SingleDelete
 		config_auto_recognize_color = localConfig.AutoRecognizeColor
+		config_auto_recognize_time = localConfig.AutoRecognizeTime
+		config_recognize_named_color = localConfig.RecognizeNamedColor
 		config_save_log_to_local = localConfig.SaveLogToLocal
+		config_share_download_dir = localConfig.ShareDownloadDir
+		if localConfig.SavedFilters != nil {
+			config_saved_filters = localConfig.SavedFilters
+		}
+		config_ocr_enable = localConfig.OcrEnable
+		config_ocr_engine = localConfig.OcrEngine
+		config_ocr_command = localConfig.OcrCommand
+		config_ocr_language = localConfig.OcrLanguage
+		config_menu_thumbnail = localConfig.MenuThumbnail
+		if localConfig.Snippets != nil {
+			config_snippets = localConfig.Snippets
+		}
+		config_dedupe_policy = localConfig.DedupePolicy
+		config_history_order = localConfig.HistoryOrder
+		config_sensitive_detect = localConfig.SensitiveDetect
+		config_sensitive_expire = localConfig.SensitiveExpire
+		config_encrypt_enable = localConfig.EncryptEnable
+		config_encrypt_key_source = localConfig.EncryptKeySource
+		if localConfig.IgnoreApps != nil {
+			config_ignore_apps = localConfig.IgnoreApps
+		}
+		if localConfig.IgnorePatterns != nil {
+			config_ignore_patterns = localConfig.IgnorePatterns
+		}
+		config_control_api = localConfig.ControlApi
+		config_retention = localConfig.Retention
+		if localConfig.MenuPageSize > 0 {
+			config_menu_page_size = localConfig.MenuPageSize
+		}
+		if localConfig.MenuRecentCount > 0 {
+			config_menu_recent_count = localConfig.MenuRecentCount
+		}
+		config_hotkey_enable = localConfig.HotkeyEnable
+		config_hotkeys = localConfig.Hotkeys
+		config_picker_font = localConfig.PickerFont
+		config_auto_paste = localConfig.AutoPaste
 
 		history.SetMaxSize(config_history_max)
-
-		// 加载本地历史记录
-		if localConfig.Data.History != nil{
-			history.items = localConfig.Data.History
-		}
-		if localConfig.Data.Groups != nil{
-			for name, groupData := range localConfig.Data.Groups{
-				groups[name] = NewGroup(name, groupData.Active, const_max_history)
-				if groupData.History != nil{
-					groups[name].History.items = groupData.History
+		history.SetDedupePolicy(config_dedupe_policy)
+		history.SetRetention(config_retention)
+
+		// 加载本地历史记录，加密保存的历史记录需要解锁后才能加载
+		loadHistoryData(localConfig.Data)
+		if config_encrypt_enable && localConfig.EncryptedData != nil {
+			lockedData = localConfig.EncryptedData
+			global_encrypt_salt = lockedData.Salt
+			global_locked = true
+			if config_encrypt_key_source == KeySourceKeyring {
+				key, err := loadKeyringKey()
+				if err == nil {
+					err = unlockHistory(key)
+				}
+				if err != nil {
+					global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("从系统钥匙串解锁历史记录失败: %v", err)}
 				}
 			}
 		}
 
-		groupNames = localConfig.Data.GroupNames
+		if config_ocr_enable {
+			startOcr(history, groups)
+		}
 
 		return func() {
 			global_log_channel <- LogEntry{Kind: KindInfo, Content: "正在保存配置和历史记录..."}
@@ -211,16 +417,65 @@ func main() {
 			config.HistoryMax = config_history_max
 			config.SingleDelete = config_single_delete
 			config.AutoRecognizeColor = config_auto_recognize_color
+			config.AutoRecognizeTime = config_auto_recognize_time
+			config.RecognizeNamedColor = config_recognize_named_color
 			config.SaveLogToLocal = config_save_log_to_local
-			config.Data.History = history.GetAll()
+			config.ShareDownloadDir = config_share_download_dir
+			config.SavedFilters = config_saved_filters
+			config.OcrEnable = config_ocr_enable
+			config.OcrEngine = config_ocr_engine
+			config.OcrCommand = config_ocr_command
+			config.OcrLanguage = config_ocr_language
+			config.MenuThumbnail = config_menu_thumbnail
+			config.Snippets = config_snippets
+			config.DedupePolicy = config_dedupe_policy
+			config.HistoryOrder = config_history_order
+			config.SensitiveDetect = config_sensitive_detect
+			config.SensitiveExpire = config_sensitive_expire
+			config.EncryptEnable = config_encrypt_enable
+			config.EncryptKeySource = config_encrypt_key_source
+			config.IgnoreApps = config_ignore_apps
+			config.IgnorePatterns = config_ignore_patterns
+			config.ControlApi = config_control_api
+			config.Retention = config_retention
+			config.MenuPageSize = config_menu_page_size
+			config.MenuRecentCount = config_menu_recent_count
+			config.HotkeyEnable = config_hotkey_enable
+			config.Hotkeys = config_hotkeys
+			config.PickerFont = config_picker_font
+			config.AutoPaste = config_auto_paste
+			config.Data.History = withOcrText(withoutSensitive(history.GetAll()))
 			for name, group := range groups {
 				config.Data.Groups[name] = HistoryGroupData{
 					Active: group.Active,
-					History: group.History.GetAll(),
+					History: withOcrText(withoutSensitive(group.History.GetAll())),
+					Retention: group.History.Retention(),
 				}
 			}
 			config.Data.GroupNames = groupNames
 
+			// 加密保存历史记录和分组
+			if config_encrypt_enable {
+				if lockedData != nil {
+					global_log_channel <- LogEntry{Kind: KindError, Content: "历史记录未解锁，锁定期间的新记录不会保存"}
+					config.EncryptedData = lockedData
+				} else if encrypted, err := encryptHistoryData(config.Data, global_encrypt_key, global_encrypt_salt); err == nil {
+					config.EncryptedData = encrypted
+				} else {
+					global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("加密历史记录失败，不保存历史记录: %v", err)}
+				}
+				config.Data = NewDefaultConfig().Data
+				cleanImageStore(nil)
+			} else {
+				stored := map[string]bool{}
+				config.Data.History = storeImages(config.Data.History, stored)
+				for name, group := range config.Data.Groups {
+					group.History = storeImages(group.History, stored)
+					config.Data.Groups[name] = group
+				}
+				cleanImageStore(stored)
+			}
+
 			data, _ := json.Marshal(config)
 			os.WriteFile(getConfigPath(), data, 0644)
 		}
@@ -239,24 +494,60 @@ func main() {
 			for item := range reader {
 				succ := history.Add(item)
 				if succ{
-					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("新剪贴板内容: %s", formatMenuItem(item))}
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("新剪贴板内容: %s", formatLogItem(item))}
 				}
 
+				// 识别结果按内容哈希缓存，分组中的副本不需要重复提交
+				worker := global_ocr_worker.Load()
 				for _, group := range groups {
 					if group.Active {
 						global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("添加到分组 %s", group.Name)}
-						group.History.Add(item.Clone())
+						if group.History.Add(item.Clone()) && !succ && worker != nil {
+							worker.Submit(item)
+						}
 					}
 				}
 
-				if succ && global_history_share_server != nil{
+				if succ && worker != nil {
+					worker.Submit(item)
+				}
+
+				// 敏感内容不共享，文件列表只在右键选择发送时共享
+				if succ && !item.Sensitive && item.Type != TypeFiles && global_history_share_server != nil{
 					global_log_channel <- LogEntry{Kind: KindInfo, Content: "共享到局域网"}
 					global_history_share_server.Share(item.CloneToRemote())
 				}
 			}
 		}()
+		// 定时删除超出保留策略的记录和过期的敏感内容
+		expireTicker := time.NewTicker(30 * time.Second)
+		go func() {
+			for range expireTicker.C {
+				swept := history.Sweep()
+				for _, group := range groups {
+					swept += group.History.Sweep()
+				}
+				if swept > 0 {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("按保留策略删除%d条记录", swept)}
+				}
+
+				if config_sensitive_expire == 0 {
+					continue
+				}
+				before := time.Now().Add(-time.Duration(config_sensitive_expire) * time.Minute)
+				removed := history.RemoveExpiredSensitive(before)
+				for _, group := range groups {
+					removed += group.History.RemoveExpiredSensitive(before)
+				}
+				if removed > 0 {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("删除%d条过期的敏感内容", removed)}
+				}
+			}
+		}()
+
 		return writer, nil, func() {
 			global_log_channel <- LogEntry{Kind: KindInfo, Content: "关闭所有监听..."}
+			expireTicker.Stop()
 			if global_history_share_server != nil {
 				global_history_share_server.Stop()
 			}
@@ -275,33 +566,355 @@ func main() {
 		systray.SetIcon(logo)
 		systray.SetTooltip("Clip")
 
+		recognizeColor := func(item *ClipItem) (Color, bool) {
+			if !config_auto_recognize_color || item.Type != TypeText || item.Sensitive {
+				return Color{}, false
+			}
+			return parseColor(string(item.Content))
+		}
+
+		recognizeTime := func(item *ClipItem) (time.Time, bool) {
+			if !config_auto_recognize_time || item.Type != TypeText || item.Sensitive {
+				return time.Time{}, false
+			}
+			return parseTimestamp(strings.TrimSpace(string(item.Content)))
+		}
+
 		addColorRecognizeMenuAction := func (menu *systray.MenuItem, item *ClipItem) bool  {
-			if !config_auto_recognize_color || item.Type != TypeText{
+			c, ok := recognizeColor(item)
+			if !ok {
 				return false
 			}
 
-			r,g,b,base,ok := getColor(string(item.Content))
-			if ok {
-				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("识别颜色成功: %s,并添加菜单", string(item.Content))}
-				rt,_ := strconv.ParseInt(r,base,0)
-				gt,_ := strconv.ParseInt(g,base,0)
-				bt,_ := strconv.ParseInt(b,base,0)
-				hexT := fmt.Sprintf("#%x%x%x", rt, gt, bt)
-				rgbT := fmt.Sprintf("%d,%d,%d", rt, gt, bt)
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("识别颜色成功: %s,并添加菜单", string(item.Content))}
+			menu.SetIcon(colorSwatch(c))
+			for _, format := range color_formats {
+				text := format.Format(c)
+				if text == "" {
+					continue
+				}
+				menu.AddSubMenuItem("复制" + format.Name, text).Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("复制%s颜色: %s", format.Name, text)}
+					writer <- NewClipItem(TypeText, []byte(text))
+				})
+			}
+			return true
+		}
 
-				copyH := menu.AddSubMenuItem("复制Hex", "")
-				copyRGB := menu.AddSubMenuItem("复制RGB", "")
-				copyH.Click(func() {
-					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("复制Hex颜色: %s", hexT)}
-					writer <- NewClipItem(TypeText, []byte(hexT))
+		addTimeRecognizeMenuAction := func(menu *systray.MenuItem, item *ClipItem) bool {
+			t, ok := recognizeTime(item)
+			if !ok {
+				return false
+			}
+
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("识别时间成功: %s,并添加菜单", string(item.Content))}
+			for _, format := range time_formats {
+				text := format.Format(t)
+				menu.AddSubMenuItem("复制" + format.Name, text).Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("复制%s时间: %s", format.Name, text)}
+					writer <- NewClipItem(TypeText, []byte(text))
 				})
-				copyRGB.Click(func() {
-					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("复制RGB颜色: %s", rgbT)}
-					writer <- NewClipItem(TypeText, []byte(rgbT))
+			}
+			return true
+		}
+
+		// 右键菜单中条目的附加操作
+		// 敏感内容不提供转换等操作，避免结果在菜单和日志中显示
+		hasItemExtraMenuAction := func(item *ClipItem) bool {
+			return item.Type == TypeImage || (item.Type == TypeText && !item.Sensitive)
+		}
+
+		// 按内容类型添加操作
+		addContentKindMenuAction := func(menu *systray.MenuItem, item *ClipItem) {
+			detector, ok := getContentDetector(item.Kind)
+			if !ok {
+				return
+			}
+
+			text := strings.TrimSpace(string(item.Content))
+			if detector.Open != nil {
+				menu.AddSubMenuItem(detector.Icon + " 打开", detector.Open(text)).Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("打开%s: %s", detector.Name, detector.Open(text))}
+					if err := openExternal(detector.Open(text)); err != nil {
+						global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("打开%s失败: %v", detector.Name, err)}
+					}
 				})
-				return true
-			}	
-			return false
+			}
+			for _, action := range detector.Actions {
+				menu.AddSubMenuItem(action.Name, "").Click(func() {
+					result, err := action.Apply(text)
+					if err != nil {
+						global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("%s失败: %v", action.Name, err)}
+						return
+					}
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("%s: %s", action.Name, truncateString(result, 40))}
+					writer <- NewClipItem(TypeText, []byte(result))
+				})
+			}
+		}
+
+		// JSON/YAML/TOML 结构化数据工具，错误会通知用户
+		addDataMenuAction := func(menu *systray.MenuItem, item *ClipItem) {
+			text := strings.TrimSpace(string(item.Content))
+			looksLikeJSON := strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[")
+			if item.Kind != ContentJSON && item.Kind != ContentYAML && item.Kind != ContentTOML && !looksLikeJSON {
+				return
+			}
+
+			dataMenu := menu.AddSubMenuItem("数据", "")
+			addDataAction := func(name string, apply func(text string) (string, error)) {
+				dataMenu.AddSubMenuItem(name, "").Click(func() {
+					result, err := apply(text)
+					if err != nil {
+						reportError(fmt.Sprintf("%s失败: %v", name, err))
+						return
+					}
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("%s: %s", name, truncateString(result, 40))}
+					writer <- NewClipItem(TypeText, []byte(result))
+				})
+			}
+
+			switch item.Kind {
+			case ContentYAML:
+				addDataAction("转换为JSON", yamlToJSON)
+				addDataAction("转换为TOML", yamlToTOML)
+			case ContentTOML:
+				addDataAction("转换为JSON", tomlToJSON)
+				addDataAction("转换为YAML", tomlToYAML)
+			default:
+				for _, transformer := range text_transformers {
+					if transformer.Name == "JSON格式化" || transformer.Name == "JSON压缩" {
+						addDataAction(transformer.Name, transformer.Apply)
+					}
+				}
+				addDataAction("转换为YAML", jsonToYAML)
+				addDataAction("转换为TOML", jsonToTOML)
+				dataMenu.AddSubMenuItem("按JSONPath提取", "【按JSONPath提取】使用最新剪贴板内容作为JSONPath，如 $.data[0].name").Click(func() {
+					top := history.GetTop()
+					if top == nil || top.Type != TypeText || !strings.HasPrefix(strings.TrimSpace(string(top.Content)), "$") {
+						reportError("按JSONPath提取失败: 请先复制以$开头的JSONPath")
+						return
+					}
+					result, err := extractJSONPath(text, string(top.Content))
+					if err != nil {
+						reportError(fmt.Sprintf("按JSONPath提取失败: %v", err))
+						return
+					}
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("按JSONPath提取: %s", truncateString(result, 40))}
+					writer <- NewClipItem(TypeText, []byte(result))
+				})
+				dataMenu.AddSubMenuItem("校验", "").Click(func() {
+					result, err := validateJSON(text)
+					if err != nil {
+						reportError(fmt.Sprintf("JSON校验失败: %v", err))
+						return
+					}
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: result}
+					notify("Clip", result)
+				})
+			}
+		}
+
+		addTransformMenuAction := func(menu *systray.MenuItem, item *ClipItem) {
+			transformMenu := menu.AddSubMenuItem("转换", "")
+			for _, transformer := range text_transformers {
+				transformMenu.AddSubMenuItem(transformer.Name, "").Click(func() {
+					result, err := transformer.Apply(string(item.Content))
+					if err != nil {
+						global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("转换(%s)失败: %v", transformer.Name, err)}
+						return
+					}
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("转换(%s): %s", transformer.Name, truncateString(result, 40))}
+					writer <- NewClipItem(TypeText, []byte(result))
+				})
+			}
+		}
+
+		// 在后台处理图片，结果写入剪贴板
+		transformImage := func(name string, item *ClipItem, transform func(data []byte) (*ClipItem, error)) func() {
+			return func() {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("处理图片(%s): %s", name, formatMenuItem(item))}
+				go func() {
+					result, err := transform(item.Content)
+					if err != nil {
+						global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("处理图片(%s)失败: %v", name, err)}
+						return
+					}
+					writer <- result
+				}()
+			}
+		}
+
+		toImageItem := func(transform func(data []byte) ([]byte, error)) func(data []byte) (*ClipItem, error) {
+			return func(data []byte) (*ClipItem, error) {
+				result, err := transform(data)
+				if err != nil {
+					return nil, err
+				}
+				return NewClipItem(TypeImage, result), nil
+			}
+		}
+
+		addImageMenuAction := func(menu *systray.MenuItem, item *ClipItem) {
+			imageMenu := menu.AddSubMenuItem("图片处理", "")
+			resizeMenu := imageMenu.AddSubMenuItem("缩放宽度", "")
+			info, _ := getImageInfo(item.Content)
+			for _, width := range []int{1920, 1280, 800, 400} {
+				if info.Width > 0 && info.Width <= width {
+					continue
+				}
+				resizeMenu.AddSubMenuItem(fmt.Sprintf("%dpx", width), "").Click(transformImage(fmt.Sprintf("缩放到%dpx", width), item, toImageItem(func(data []byte) ([]byte, error) {
+					return resizeImageWidth(data, width)
+				})))
+			}
+
+			// 转换后的图片保留原格式保存在历史记录中，写入剪贴板时转换为PNG
+			jpegMenu := imageMenu.AddSubMenuItem("转换为JPEG", "")
+			for _, quality := range []int{90, 75, 50} {
+				jpegMenu.AddSubMenuItem(fmt.Sprintf("质量 %d", quality), "").Click(transformImage(fmt.Sprintf("转换为JPEG质量%d", quality), item, toImageItem(func(data []byte) ([]byte, error) {
+					return encodeJPEG(data, quality)
+				})))
+			}
+			imageMenu.AddSubMenuItem("转换为WebP(无损)", "").Click(transformImage("转换为WebP", item, toImageItem(encodeWebP)))
+			imageMenu.AddSubMenuItem("灰度", "").Click(transformImage("灰度", item, toImageItem(grayscaleImage)))
+			imageMenu.AddSubMenuItem("去除元数据", "").Click(transformImage("去除元数据", item, toImageItem(stripImageMetadata)))
+			imageMenu.AddSubMenuItem("复制为Data URI", "").Click(transformImage("复制为Data URI", item, func(data []byte) (*ClipItem, error) {
+				return NewClipItem(TypeText, []byte(imageDataURI(data))), nil
+			}))
+		}
+
+		addItemExtraMenuAction := func(menu *systray.MenuItem, item *ClipItem) {
+			if item.Type == TypeImage {
+				if text := ocrText(item); text != "" {
+					menu.AddSubMenuItem("复制识别文字", text).Click(func() {
+						global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("复制识别文字: %s", formatMenuItem(item))}
+						writer <- NewClipItem(TypeText, []byte(text))
+					})
+				}
+				addImageMenuAction(menu, item)
+			}
+			if item.Type == TypeText {
+				addContentKindMenuAction(menu, item)
+				addDataMenuAction(menu, item)
+				addTransformMenuAction(menu, item)
+			}
+		}
+
+		// 记录当前活动窗口，自动粘贴时切换回该窗口
+		rememberPasteTarget := func() {
+			if !config_auto_paste.Any() {
+				return
+			}
+			if target, ok := activeWindow(); ok {
+				global_paste_target.Store(&target)
+			}
+		}
+		// Windows 点击托盘图标时任务栏会成为活动窗口，开启自动粘贴时监听前台窗口的变化
+		updateForegroundWatch := func() {
+			if config_auto_paste.Any() && global_stop_foreground_watch == nil {
+				stop, err := watchForeground(func(target pasteTarget) {
+					global_paste_target.Store(&target)
+				})
+				if err != nil {
+					reportError(fmt.Sprintf("自动粘贴: %v", err))
+					return
+				}
+				global_stop_foreground_watch = stop
+			} else if !config_auto_paste.Any() && global_stop_foreground_watch != nil {
+				global_stop_foreground_watch()
+				global_stop_foreground_watch = nil
+				global_paste_target.Store(nil)
+			}
+		}
+		updateForegroundWatch()
+
+		// 写入剪贴板，开启自动粘贴时粘贴到之前的活动窗口，left 为粘贴后光标左移的字符数
+		pasteItem := func(item *ClipItem, left int) {
+			if !config_auto_paste.Enabled(item.Type) {
+				writer <- item
+				return
+			}
+			target := pasteTarget{}
+			if remembered := global_paste_target.Load(); remembered != nil {
+				target = *remembered
+			}
+			// 等待写入剪贴板完成后再粘贴，图片较大时写入可能超过固定的等待时间
+			written := global_write_waiter.Wait(item)
+			writer <- item
+			go func() {
+				start := time.Now()
+				select {
+				case <-written:
+				case <-time.After(const_paste_timeout):
+					global_write_waiter.Cancel(item, written)
+					reportError("自动粘贴失败: 写入剪贴板超时")
+					return
+				}
+				time.Sleep(const_paste_delay - time.Since(start))
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("自动粘贴: %s", formatLogItem(item))}
+				if err := pasteTo(target, left); err != nil {
+					reportError(fmt.Sprintf("自动粘贴失败: %v", err))
+				}
+			}()
+		}
+
+		// 添加条目的复制、删除、固定等操作，label 用于日志，h 为条目所在的历史记录
+		addItemMenuAction := func(menu *systray.MenuItem, item *ClipItem, label string, h *History) {
+			copyItem := func() {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("复制%s: %s", label, formatMenuItem(item))}
+				h.MarkUsed(item)
+				pasteItem(item, 0)
+			}
+
+			if item.Type == TypeImage && config_menu_thumbnail {
+				if thumb, ok := global_thumbnail_cache.Get(item); ok {
+					menu.SetIcon(thumb)
+				}
+			}
+
+			// 识别出颜色或时间时添加转换的子菜单，部分平台点击带有子菜单的条目不会触发点击
+			// 子菜单的第一项始终是复制原内容
+			_, isColor := recognizeColor(item)
+			_, isTime := recognizeTime(item)
+			if global_show_menu_state == Click {
+				menu.Click(copyItem)
+				if !isColor && !isTime {
+					return
+				}
+			}
+			menu.AddSubMenuItem("复制", "").Click(copyItem)
+			if !addColorRecognizeMenuAction(menu, item) {
+				addTimeRecognizeMenuAction(menu, item)
+			}
+			if global_show_menu_state == Click {
+				return
+			}
+			if item.Type == TypeText {
+				menu.AddSubMenuItemCheckbox("敏感内容", "【敏感内容】敏感内容在菜单中隐藏，不会共享和保存到本地，开启自动删除时超时后删除", item.Sensitive).Click(func() {
+					// 先标记再记录日志，标记为敏感的内容在日志中隐藏
+					h.SetSensitive(item, !item.Sensitive)
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("%s敏感内容: %s", Ifel(item.Sensitive, "标记为", "取消标记"), formatMenuItem(item))}
+				})
+			}
+			menu.AddSubMenuItemCheckbox("固定", "【固定】固定的条目显示在菜单顶部，不会因超出最大条数或清空历史记录而被删除", item.Pinned).Click(func() {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("%s%s: %s", Ifel(item.Pinned, "取消固定", "固定"), label, formatMenuItem(item))}
+				h.SetPinned(item, !item.Pinned)
+			})
+			if item.Type == TypeFiles && global_history_share_server != nil {
+				menu.AddSubMenuItem("发送文件到局域网", "【发送文件到局域网】将文件内容发送给已连接的设备，复制的文件不会自动发送").Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("发送文件到局域网: %s", formatMenuItem(item))}
+					global_history_share_server.ShareFiles(item.CloneToRemote())
+				})
+			}
+			if config_single_delete {
+				menu.AddSubMenuItem("删除", "").Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("删除%s: %s", label, formatMenuItem(item))}
+					h.Remove(item)
+				})
+			}
+			if hasItemExtraMenuAction(item) {
+				addItemExtraMenuAction(menu, item)
+			}
 		}
 
 		addSeparator := func() {
@@ -317,54 +930,236 @@ func main() {
 			})
 		}
 
+		// 在子菜单中添加条目，超过一页的条目放在"更多"子菜单中，budget 为本次菜单剩余可添加的条目数
+		// 菜单每次打开都会重新创建，限制条目总数避免创建过多的系统菜单项
+		addPagedMenuItems := func(parent *systray.MenuItem, items []*ClipItem, label string, h *History, budget *int) {
+			pageSize := int(config_menu_page_size)
+			addItems := func(menu *systray.MenuItem, items []*ClipItem) {
+				for _, item := range items {
+					sub := menu.AddSubMenuItem(formatMenuItem(item), formatMenuItemTooltip(item))
+					addItemMenuAction(sub, item, label, h)
+				}
+			}
+
+			shown := min(len(items), max(*budget, 0))
+			*budget -= shown
+			addItems(parent, items[:min(pageSize, shown)])
+			for start := pageSize; start < shown; start += pageSize {
+				end := min(start + pageSize, shown)
+				addItems(parent.AddSubMenuItem(fmt.Sprintf("更多 (%d-%d)", start + 1, end), ""), items[start:end])
+			}
+			if len(items) > shown {
+				parent.AddSubMenuItem(fmt.Sprintf("… 还有%d条，使用搜索查找", len(items) - shown), "").Disable()
+			}
+		}
+
+		// 固定的历史记录显示在菜单顶部
+		addPinnedMenuAction := func() bool {
+			if global_locked {
+				return false
+			}
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加固定的历史记录项"}
+			pinned := history.GetPinned()
+			if global_search_enable {
+				pinned = SearchItems(global_search_query, pinned, "")
+			}
+			for _, item := range pinned {
+				menu := systray.AddMenuItem(formatMenuItem(item), formatMenuItemTooltip(item))
+				addItemMenuAction(menu, item, "固定项", history)
+			}
+
+			return len(pinned) > 0
+		}
+
 		addHistoryMenuAction := func() bool {
+			if global_locked {
+				return false
+			}
 			global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加历史记录项"}
-			all := history.GetAll()
-			for i, item := range all {
-				if global_search_enable {
-					if !strings.Contains(string(item.Content), global_search_text){
-						continue
+
+			// 顶部显示最近的条目，按时间排序时更早的条目按日期分组显示在子菜单中
+			recentCount := int(config_menu_recent_count)
+			if !global_search_enable && config_history_order == OrderTime {
+				recent, total := history.GetPage(0, recentCount)
+				for _, item := range recent {
+					menu := systray.AddMenuItem(formatMenuItem(item), formatMenuItemTooltip(item))
+					addItemMenuAction(menu, item, "历史记录项", history)
+				}
+				if total > len(recent) {
+					rest, _ := history.GetPage(recentCount, total)
+					budget := menuItemBudget(global_show_menu_state)
+					for _, bucket := range bucketItemsByDate(rest, time.Now()) {
+						menu := systray.AddMenuItem(fmt.Sprintf("📅 %s (%d)", bucket.Name, len(bucket.Items)), "")
+						addPagedMenuItems(menu, bucket.Items, "历史记录项", history, &budget)
 					}
 				}
+				return total > 0
+			}
+
+			all := history.GetAll()
+			if global_search_enable {
+				all = SearchItems(global_search_query, all, "")
+			} else {
+				all = orderItems(all, config_history_order)
+			}
+			all = slices.DeleteFunc(all, func(item *ClipItem) bool { return item.Pinned })
+			for _, item := range all[:min(recentCount, len(all))] {
 				menu := systray.AddMenuItem(formatMenuItem(item), formatMenuItemTooltip(item))
-				switch global_show_menu_state {
-				case Click:
-					if !addColorRecognizeMenuAction(menu, item){
-						menu.Click(func() {
-							writer <- item
-						})
-					} 
-				case RClick:
-					if addColorRecognizeMenuAction(menu, item){
-						if config_single_delete {
-							del := menu.AddSubMenuItem("删除", "")
-							del.Click(func() {
-								global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("删除历史记录项: %s", formatMenuItem(item))}
-								history.Delete(i)
-							})
-						}
-					}else{
-						if config_single_delete {
-							copy := menu.AddSubMenuItem("复制", "")
-							del := menu.AddSubMenuItem("删除", "")
-							copy.Click(func() {
-								global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("复制历史记录项: %s", formatMenuItem(item))}
-								writer <- item
-							})
-							del.Click(func() {
-								global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("删除历史记录项: %s", formatMenuItem(item))}
-								history.Delete(i)
-							})
-						}else {
-							menu.Click(func() {
-								writer <- item
-							})
-						}	
+				addItemMenuAction(menu, item, "历史记录项", history)
+			}
+			if len(all) > recentCount {
+				menu := systray.AddMenuItem(fmt.Sprintf("📄 更多 (%d)", len(all) - recentCount), "")
+				budget := menuItemBudget(global_show_menu_state)
+				addPagedMenuItems(menu, all[recentCount:], "历史记录项", history, &budget)
+			}
+			return len(all) > 0
+		}
+
+		copySnippet := func(snippet Snippet) {
+			clipboardText := ""
+			if top := history.GetTop(); top != nil && top.Type == TypeText {
+				clipboardText = string(top.Content)
+			}
+			text, cursor := expandSnippet(snippet.Template, clipboardText)
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("复制片段: %s", snippet.Name)}
+			// 自动粘贴后将光标移动到 {cursor} 的位置
+			pasteItem(NewClipItem(TypeText, []byte(text)), max(cursor, 0))
+		}
+
+		// 固定的片段显示在菜单顶部
+		addPinnedSnippetMenuAction := func() bool {
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加固定片段"}
+			count := 0
+			for _, snippet := range config_snippets {
+				if !snippet.Pinned {
+					continue
+				}
+				systray.AddMenuItem("🧩 " + snippet.Name, snippet.Template).Click(func() {
+					copySnippet(snippet)
+				})
+				count++
+			}
+			return count > 0
+		}
+
+		addSnippetMenuAction := func() {
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加`片段`菜单"}
+			menu := systray.AddMenuItem("🧩 片段", "【片段】保存常用的文本模板，支持 {date} {time} {datetime} {clipboard} {uuid} {cursor} 占位符")
+			if global_show_menu_state == RClick {
+				menu.AddSubMenuItem("➕ 添加片段", "使用最新剪贴板内容作为模板").Click(func() {
+					top := history.GetTop()
+					if top == nil || top.Type != TypeText || strings.TrimSpace(string(top.Content)) == "" {
+						global_log_channel <- LogEntry{Kind: KindError, Content: "添加片段失败: 最新的历史记录不是文本"}
+						return
 					}
+					template := string(top.Content)
+					config_snippets = append(config_snippets, Snippet{Name: snippetName(template), Template: template})
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("添加片段: %s", snippetName(template))}
+				})
+			}
+
+			for i, snippet := range config_snippets {
+				title := Ifel(snippet.Pinned, "📌 ", "") + snippet.Name
+				if global_show_menu_state == Click {
+					menu.AddSubMenuItem(title, snippet.Template).Click(func() {
+						copySnippet(snippet)
+					})
+					continue
 				}
+
+				snippetMenu := menu.AddSubMenuItem(title, snippet.Template)
+				snippetMenu.AddSubMenuItem("复制", "").Click(func() {
+					copySnippet(snippet)
+				})
+				snippetMenu.AddSubMenuItemCheckbox("固定到顶部", "", snippet.Pinned).Click(func() {
+					config_snippets[i].Pinned = !snippet.Pinned
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("%s片段: %s", Ifel(snippet.Pinned, "取消固定", "固定"), snippet.Name)}
+				})
+				snippetMenu.AddSubMenuItem("重命名", "使用最新剪贴板内容作为片段名").Click(func() {
+					top := history.GetTop()
+					if top == nil || top.Type != TypeText {
+						global_log_channel <- LogEntry{Kind: KindError, Content: "重命名片段失败: 最新的历史记录不是文本"}
+						return
+					}
+					config_snippets[i].Name = truncateString(strings.TrimSpace(string(top.Content)), 20)
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("重命名片段: %s", snippet.Name)}
+				})
+				snippetMenu.AddSubMenuItem("删除", "").Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("删除片段: %s", snippet.Name)}
+					config_snippets = append(config_snippets[:i], config_snippets[i+1:]...)
+				})
 			}
+		}
 
-			return len(all) > 0
+		// 保留策略菜单，onChange 用于保存修改后的策略
+		addRetentionMenuAction := func(menu *systray.MenuItem, label string, h *History, onChange func(policy RetentionPolicy)) {
+			policy := h.Retention()
+			retentionMenu := menu.AddSubMenuItem("保留策略", "【保留策略】超出限制的最早记录会在添加新记录和定时清理时删除，固定的条目除外")
+			addOptions := func(title string, options []uint, current uint, format func(uint) string, set func(p *RetentionPolicy, value uint)) {
+				optionMenu := retentionMenu.AddSubMenuItem(fmt.Sprintf("%s(当前: %s)", title, format(current)), "")
+				for _, value := range options {
+					optionMenu.AddSubMenuItemCheckbox(format(value), "", value == current).Click(func() {
+						global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置%s%s: %s", label, title, format(value))}
+						p := h.Retention()
+						set(&p, value)
+						h.SetRetention(p)
+						if onChange != nil {
+							onChange(p)
+						}
+					})
+				}
+			}
+			formatCount := func(value uint) string {
+				return Ifel(value == 0, "不限制", fmt.Sprintf("%d条", value))
+			}
+			formatMB := func(value uint) string {
+				return Ifel(value == 0, "不限制", fmt.Sprintf("%dMB", value))
+			}
+
+			addOptions("最长保留时间", retention_age_options, policy.MaxAge, formatDays, func(p *RetentionPolicy, value uint) { p.MaxAge = value })
+			addOptions("最大占用空间", retention_bytes_options, policy.MaxBytes, formatMB, func(p *RetentionPolicy, value uint) { p.MaxBytes = value })
+			addOptions("最多图片条数", retention_images_options, policy.MaxImages, formatCount, func(p *RetentionPolicy, value uint) { p.MaxImages = value })
+			addOptions("远程条目最多条数", retention_remote_options, policy.MaxRemote, formatCount, func(p *RetentionPolicy, value uint) { p.MaxRemote = value })
+			addOptions("远程条目最长保留时间", retention_age_options, policy.RemoteMaxAge, formatDays, func(p *RetentionPolicy, value uint) { p.RemoteMaxAge = value })
+		}
+
+		// 锁定时只显示解锁菜单，启用加密后右键菜单可以锁定
+		addLockMenuAction := func() bool {
+			if global_locked {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加`解锁`菜单"}
+				tooltip := Ifel(config_encrypt_key_source == KeySourcePassphrase, "【解锁】点击后复制密码解锁，密码不会记录到历史记录", "【解锁】使用系统钥匙串中的密钥解锁")
+				systray.AddMenuItem("🔒 历史记录已锁定，点击解锁", tooltip).Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: "解锁历史记录"}
+					unlock := func(key []byte, err error) {
+						if err == nil {
+							err = unlockHistory(key)
+						}
+						if err != nil {
+							reportError(fmt.Sprintf("解锁历史记录失败: %v", err))
+						}
+					}
+					if config_encrypt_key_source == KeySourceKeyring {
+						unlock(loadKeyringKey())
+						return
+					}
+					// 密码直接从剪贴板读取，不会记录到历史记录
+					salt := global_encrypt_salt
+					awaitPassphrase(func(passphrase string) {
+						unlock(deriveKey(passphrase, salt))
+					})
+				})
+				return true
+			}
+
+			if config_encrypt_enable && global_show_menu_state == RClick {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加`锁定`菜单"}
+				systray.AddMenuItem("🔒 锁定历史记录", "【锁定历史记录】锁定后菜单中不显示历史记录和分组，解锁后恢复").Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: "历史记录已锁定"}
+					global_locked = true
+				})
+				return true
+			}
+			return false
 		}
 
 		addCreateGroupMenuCmd := func() {
@@ -379,7 +1174,8 @@ func main() {
 				}
 				if top.Type == TypeText {
 					text := string(top.Content)
-					groups[text] = NewGroup(text, false, const_max_history)
+					groups[text] = NewGroup(text, false, const_max_group_history)
+					groups[text].History.SetDedupePolicy(config_dedupe_policy)
 					groupNames = append(groupNames, text)
 				}else{
 					global_log_channel <- LogEntry{Kind: KindError, Content: "创建分组失败: 最新的历史记录不是文本，无法作为分组名"}
@@ -389,7 +1185,12 @@ func main() {
 		}
 
 		addGroupMenuAction := func() bool {
+			if global_locked {
+				return false
+			}
 			global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加分组项"}
+			// 所有分组共用条目数限制
+			budget := menuItemBudget(global_show_menu_state)
 			for i, name := range groupNames {
 				group := groups[name]
 				menu := systray.AddMenuItemCheckbox("📂" + name, "", group.Active)
@@ -398,6 +1199,7 @@ func main() {
 					btnActive := menu.AddSubMenuItemCheckbox("激活/取消激活分组", "", group.Active)
 					btnRename := menu.AddSubMenuItem("重命名", "")
 					btnDelete := menu.AddSubMenuItem("删除分组", "")
+					addRetentionMenuAction(menu, "分组" + group.Name, group.History, nil)
 					btnActive.Click(func() {
 						group.Active = !group.Active
 						global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("%s分组%s", Ifel(group.Active, "激活", "取消激活"), group.Name)}
@@ -426,51 +1228,18 @@ func main() {
 				}
 
 				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("添加分组菜单: %s 历史记录", group.Name)}
-				for i, item := range group.History.GetAll() {
-					if global_search_enable {
-						if !strings.Contains(string(item.Content), global_search_text){
-							continue
-						}
-					}
-					menu := menu.AddSubMenuItem(formatMenuItem(item), formatMenuItemTooltip(item))
-					switch global_show_menu_state {
-					case Click:
-						if !addColorRecognizeMenuAction(menu, item){
-							menu.Click(func() {
-								global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("复制历史记录项: %s", formatMenuItem(item))}
-								writer <- item
-							})
-						} 
-					case RClick:
-						if addColorRecognizeMenuAction(menu, item){
-							if config_single_delete {
-								del := menu.AddSubMenuItem("删除", "")
-								del.Click(func() {
-									global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("删除分组历史记录项: %s", formatMenuItem(item))}
-									history.Delete(i)
-								})
-							}
-						}else{
-							if config_single_delete {
-								copy := menu.AddSubMenuItem("复制", "")
-								del := menu.AddSubMenuItem("删除", "")
-								copy.Click(func() {
-									global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("复制分组历史记录项: %s", formatMenuItem(item))}
-									writer <- item
-								})
-								del.Click(func() {
-									global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("删除分组历史记录项: %s", formatMenuItem(item))}
-									group.History.Delete(i)
-								})
-							}else {
-								menu.Click(func() {
-									global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("复制分组历史记录项: %s", formatMenuItem(item))}
-									writer <- item
-								})
-							}	
-						}
+				// 固定的条目排在分组的最前面
+				unpinned := []*ClipItem{}
+				for _, item := range group.History.GetAll() {
+					if !item.Pinned {
+						unpinned = append(unpinned, item)
 					}
 				}
+				groupItems := append(group.History.GetPinned(), orderItems(unpinned, config_history_order)...)
+				if global_search_enable {
+					groupItems = SearchItems(global_search_query, groupItems, group.Name)
+				}
+				addPagedMenuItems(menu, groupItems, "分组历史记录项", group.History, &budget)
 			}
 
 			return len(groups) > 0
@@ -479,7 +1248,7 @@ func main() {
 		addCleanHistoryMenuCmd := func() {
 			global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加`清空历史记录`菜单"}
 			if global_clear_state == Normal {
-				menu := systray.AddMenuItem("清空历史记录", "【清空历史记录】会将历史记录清空，但是不会清空剪贴板中的内容")
+				menu := systray.AddMenuItem("清空历史记录", "【清空历史记录】会将历史记录清空，但是不会清空剪贴板中的内容，固定的条目会保留")
 				menu.Click(func() {
 					global_clear_state = ReadyToClear
 					global_log_channel <- LogEntry{Kind: KindInfo, Content: "准备清空历史记录，等待确认..."}
@@ -500,6 +1269,288 @@ func main() {
 			}
 		}
 
+		parseSearchQuery := func(text string) (*SearchQuery, error) {
+			query, err := ParseSearchQuery(text)
+			if err != nil {
+				return nil, err
+			}
+			query.InGroup = func(name string, item *ClipItem) bool {
+				for groupName, group := range groups {
+					if strings.EqualFold(groupName, name) && group.History.Contains(item) {
+						return true
+					}
+				}
+				return false
+			}
+			return query, nil
+		}
+
+		// 打开选择窗口，第一个标签页为历史记录，之后为各个分组
+		openPicker := func() error {
+			if global_locked {
+				return errors.New("历史记录已锁定")
+			}
+			tabs := []PickerTab{{Name: "历史记录", History: history}}
+			for _, name := range groupNames {
+				if group, ok := groups[name]; ok {
+					tabs = append(tabs, PickerTab{Name: name, History: group.History})
+				}
+			}
+			picker := NewPicker(tabs, parseSearchQuery, func(item *ClipItem, tab PickerTab) {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("从选择窗口复制: %s", formatMenuItem(item))}
+				tab.History.MarkUsed(item)
+				pasteItem(item, 0)
+			})
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: "打开选择窗口"}
+			rememberPasteTarget()
+			return showPicker(picker)
+		}
+
+		addPickerMenuCmd := func() {
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加`选择窗口`菜单"}
+			systray.AddMenuItem("🔍 选择窗口", "【选择窗口】输入关键字实时搜索，方向键选择，Enter复制，Tab切换分组").Click(func() {
+				if err := openPicker(); err != nil {
+					reportError(fmt.Sprintf("打开选择窗口失败: %v", err))
+				}
+			})
+		}
+
+		addPauseMenuAction := func() bool {
+			paused, until := global_pause.Status()
+			if paused {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加`恢复记录`菜单"}
+				systray.AddMenuItem("▶️ 恢复记录" + Ifel(until.IsZero(), "", fmt.Sprintf("(%s 自动恢复)", until.Format("15:04"))), "【恢复记录】恢复记录剪贴板内容").Click(func() {
+					global_pause.Resume()
+				})
+				return true
+			}
+			if global_show_menu_state != RClick {
+				return false
+			}
+
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加`暂停记录`菜单"}
+			menu := systray.AddMenuItem("⏸️ 暂停记录", "【暂停记录】暂停期间复制的内容不会被记录、添加到分组和共享，写入剪贴板不受影响")
+			for _, minutes := range []int{5, 15, 60} {
+				menu.AddSubMenuItem(fmt.Sprintf("%d分钟", minutes), "").Click(func() {
+					global_pause.Pause(time.Duration(minutes) * time.Minute)
+				})
+			}
+			menu.AddSubMenuItem("直到手动恢复", "").Click(func() {
+				global_pause.Pause(0)
+			})
+			return true
+		}
+
+		// 暂停时托盘提示显示暂停状态，非 Windows 平台图标显示为灰色
+		pausedLogo, err := grayscaleImage(logo)
+		if err != nil || runtime.GOOS == "windows" {
+			pausedLogo = logo
+		}
+		global_pause.OnChange(func(paused bool, until time.Time) {
+			systray.SetIcon(Ifel(paused, pausedLogo, logo))
+			systray.SetTooltip(Ifel(paused, "Clip - " + formatPauseStatus(paused, until), "Clip"))
+		})
+
+		startControlServer := func() {
+			server, err := NewControlServer()
+			if err != nil {
+				global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("启动本地控制接口失败: %v", err)}
+				return
+			}
+			server.Handle("GET /status", func(r *http.Request) (any, error) {
+				paused, until := global_pause.Status()
+				return map[string]any{
+					"paused": paused,
+					"until": until,
+					"status": formatPauseStatus(paused, until),
+					"locked": global_locked,
+				}, nil
+			})
+			server.Handle("POST /pause", func(r *http.Request) (any, error) {
+				minutes := 0
+				if value := r.URL.Query().Get("minutes"); value != "" {
+					var err error
+					if minutes, err = strconv.Atoi(value); err != nil || minutes < 0 {
+						return nil, fmt.Errorf("无法解析分钟数: %s", value)
+					}
+				}
+				global_pause.Pause(time.Duration(minutes) * time.Minute)
+				paused, until := global_pause.Status()
+				return formatPauseStatus(paused, until), nil
+			})
+			server.Handle("POST /resume", func(r *http.Request) (any, error) {
+				global_pause.Resume()
+				return formatPauseStatus(false, time.Time{}), nil
+			})
+			server.Handle("POST /picker", func(r *http.Request) (any, error) {
+				if err := openPicker(); err != nil {
+					return nil, err
+				}
+				return "已打开选择窗口", nil
+			})
+			server.Handle("GET /search", func(r *http.Request) (any, error) {
+				if global_locked {
+					return nil, errors.New("历史记录已锁定")
+				}
+				query, err := parseSearchQuery(r.URL.Query().Get("q"))
+				if err != nil {
+					return nil, err
+				}
+				limit, err := strconv.Atoi(r.URL.Query().Get("n"))
+				if err != nil || limit <= 0 {
+					limit = 20
+				}
+				group := r.URL.Query().Get("group")
+
+				all := []*ClipItem{}
+				if group == "" {
+					all = history.GetAll()
+				}
+				groupItems := make(map[string][]*ClipItem)
+				for name, g := range groups {
+					if group == "" || group == name {
+						groupItems[name] = g.History.GetAll()
+					}
+				}
+
+				items := []ControlItem{}
+				for _, result := range SearchAll(query, all, groupItems) {
+					if len(items) >= limit {
+						break
+					}
+					items = append(items, newControlItem(result.Item, result.Group))
+				}
+				return items, nil
+			})
+			server.Start()
+			global_control_server = server
+		}
+		if config_control_api {
+			startControlServer()
+		}
+
+		// 构建左键点击的菜单
+		buildClickMenu := func() {
+			global_show_menu_state = Click
+
+			systray.ResetMenu()
+
+			pinnedSnippet := addPinnedSnippetMenuAction()
+			if addPinnedMenuAction() || pinnedSnippet {
+				addSeparator()
+			}
+			lock := addLockMenuAction()
+			if addPauseMenuAction() || lock {
+				addSeparator()
+			}
+			if addHistoryMenuAction() {
+				addSeparator()
+			}
+			if addGroupMenuAction() {
+				addSeparator()
+			}
+			addSnippetMenuAction()
+			if !global_locked {
+				addSeparator()
+				addPickerMenuCmd()
+			}
+		}
+
+		// 循环复制时使用开始循环时的历史记录，避免写入剪贴板后顺序变化
+		var cycleMutex sync.Mutex
+		cycleItems := []*ClipItem{}
+		cycleIndex := 0
+		cycleTime := time.Time{}
+
+		runHotkeyAction := func(action string) {
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("触发全局快捷键: %s", action)}
+			if global_locked {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: "历史记录已锁定，忽略快捷键"}
+				return
+			}
+
+			switch {
+			case action == "menu":
+				err := openPicker()
+				if err == nil {
+					return
+				}
+				// 选择窗口无法打开时，Windows 交给托盘线程在鼠标位置弹出托盘菜单
+				if trayErr := showTrayMenu(); trayErr != nil {
+					reportError(fmt.Sprintf("打开选择窗口失败: %v", err))
+					return
+				}
+				global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("打开选择窗口失败，显示托盘菜单: %v", err)}
+
+			case action == "cycle":
+				cycleMutex.Lock()
+				defer cycleMutex.Unlock()
+				if time.Since(cycleTime) > 3*time.Second {
+					cycleItems, _ = history.GetPage(0, int(config_menu_page_size))
+					cycleIndex = 0
+				}
+				cycleTime = time.Now()
+				if len(cycleItems) < 2 {
+					return
+				}
+				cycleIndex = (cycleIndex + 1) % len(cycleItems)
+				writer <- cycleItems[cycleIndex]
+
+			case strings.HasPrefix(action, "paste:"):
+				// 第1条为当前剪贴板内容之前的一条
+				n, _ := strconv.Atoi(strings.TrimPrefix(action, "paste:"))
+				items, _ := history.GetPage(n, 1)
+				if len(items) == 0 {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("没有第%d条历史记录", n)}
+					return
+				}
+				history.MarkUsed(items[0])
+				rememberPasteTarget()
+				pasteItem(items[0], 0)
+			}
+		}
+
+		startHotkeys := func() error {
+			hotkeys, actions, err := buildHotkeys(config_hotkeys)
+			if err != nil {
+				return err
+			}
+			if len(hotkeys) == 0 {
+				return nil
+			}
+			stop, err := registerHotkeys(hotkeys, func(index int) {
+				runHotkeyAction(actions[index])
+			})
+			if err != nil {
+				return err
+			}
+			global_stop_hotkeys = stop
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("已注册%d个全局快捷键", len(hotkeys))}
+			return nil
+		}
+		stopHotkeys := func() {
+			if global_stop_hotkeys != nil {
+				global_stop_hotkeys()
+				global_stop_hotkeys = nil
+			}
+		}
+		// 修改快捷键后重新注册，注册失败时关闭全局快捷键，避免菜单显示已启用但没有注册任何快捷键
+		restartHotkeys := func() {
+			if !config_hotkey_enable {
+				return
+			}
+			stopHotkeys()
+			if err := startHotkeys(); err != nil {
+				reportError(fmt.Sprintf("注册全局快捷键失败: %v", err))
+				config_hotkey_enable = false
+			}
+		}
+		if config_hotkey_enable {
+			if err := startHotkeys(); err != nil {
+				reportError(fmt.Sprintf("注册全局快捷键失败: %v", err))
+			}
+		}
+
 		addConfigMenuAction := func() {
 			global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加`配置`菜单"}
 
@@ -512,7 +1563,15 @@ func main() {
 				config_auto_recognize_color = !config_auto_recognize_color
 				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置自动识别颜色: %v", config_auto_recognize_color)}
 			})
-			menu.AddSubMenuItem("设置最大历史记录条数" + fmt.Sprintf("(当前: %d)", config_history_max), "【设置最大历史记录条数】会设置历史记录的最大条数，超过最大条数会自动删除最早的记录，范围：1-300").Click(func() {
+			menu.AddSubMenuItemCheckbox("识别颜色名称", "【识别颜色名称】将 red、tan 等 CSS 颜色名称识别为颜色，关闭时只识别 #RRGGBB、rgb() 等格式", config_recognize_named_color).Click(func() {
+				config_recognize_named_color = !config_recognize_named_color
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置识别颜色名称: %v", config_recognize_named_color)}
+			})
+			menu.AddSubMenuItemCheckbox("自动识别时间", "【自动识别时间】识别Unix时间戳和日期，点击条目可以复制为其他时间格式", config_auto_recognize_time).Click(func() {
+				config_auto_recognize_time = !config_auto_recognize_time
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置自动识别时间: %v", config_auto_recognize_time)}
+			})
+			menu.AddSubMenuItem("设置最大历史记录条数" + fmt.Sprintf("(当前: %d)", config_history_max), "【设置最大历史记录条数】会设置历史记录的最大条数，超过最大条数会自动删除最早的记录，范围：1-" + fmt.Sprint(const_max_history)).Click(func() {
 				global_log_channel <- LogEntry{Kind: KindInfo, Content: "设置最大历史记录条数"}
 				top := history.GetTop()
 				if top == nil || top.Type != TypeText {
@@ -526,7 +1585,7 @@ func main() {
 					return
 				}
 
-				if digit > 300 || digit <= 0 {
+				if digit > uint64(const_max_history) || digit <= 0 {
 					global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("设置最大历史记录条数失败: 数字超出范围: %d", digit)}
 					return
 				}
@@ -534,6 +1593,228 @@ func main() {
 				config_history_max = uint(digit)
 				history.SetMaxSize(config_history_max)
 			})
+			encryptMenu := menu.AddSubMenuItem("加密保存历史记录", "【加密保存历史记录】历史记录和分组加密后保存到本地，启动时需要解锁")
+			encryptMenu.AddSubMenuItemCheckbox("使用系统钥匙串", "【使用系统钥匙串】随机生成密钥保存在系统钥匙串中，启动时自动解锁", config_encrypt_enable && config_encrypt_key_source == KeySourceKeyring).Click(func() {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: "使用系统钥匙串加密历史记录"}
+				if global_locked {
+					reportError("设置加密失败: 请先解锁历史记录")
+					return
+				}
+				key, err := createKeyringKey()
+				if err != nil {
+					reportError(fmt.Sprintf("设置加密失败: 无法访问系统钥匙串: %v", err))
+					return
+				}
+				config_encrypt_enable = true
+				config_encrypt_key_source = KeySourceKeyring
+				global_encrypt_key = key
+				global_encrypt_salt = nil
+			})
+			encryptMenu.AddSubMenuItemCheckbox("使用密码", fmt.Sprintf("【使用密码】点击后复制至少%d个字符的密码，密码不会记录到历史记录", min_passphrase), config_encrypt_enable && config_encrypt_key_source == KeySourcePassphrase).Click(func() {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: "使用密码加密历史记录"}
+				if global_locked {
+					reportError("设置加密失败: 请先解锁历史记录")
+					return
+				}
+				// 密码直接从剪贴板读取，不会记录到历史记录
+				awaitPassphrase(func(passphrase string) {
+					if len([]rune(passphrase)) < min_passphrase {
+						reportError(fmt.Sprintf("设置加密失败: 密码至少%d个字符", min_passphrase))
+						return
+					}
+					salt := newSalt()
+					key, err := deriveKey(passphrase, salt)
+					if err != nil {
+						reportError(fmt.Sprintf("设置加密失败: %v", err))
+						return
+					}
+					if config_encrypt_key_source == KeySourceKeyring {
+						deleteKeyringKey()
+					}
+					config_encrypt_enable = true
+					config_encrypt_key_source = KeySourcePassphrase
+					global_encrypt_key = key
+					global_encrypt_salt = salt
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: "已使用密码加密历史记录"}
+				})
+			})
+			if config_encrypt_enable {
+				encryptMenu.AddSubMenuItem("关闭加密", "【关闭加密】历史记录将以明文保存到本地").Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: "关闭加密保存历史记录"}
+					if global_locked {
+						reportError("关闭加密失败: 请先解锁历史记录")
+						return
+					}
+					if config_encrypt_key_source == KeySourceKeyring {
+						if err := deleteKeyringKey(); err != nil {
+							global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("删除系统钥匙串中的密钥失败: %v", err)}
+						}
+					}
+					config_encrypt_enable = false
+					global_encrypt_key = nil
+					global_encrypt_salt = nil
+				})
+			}
+			menu.AddSubMenuItemCheckbox("本地控制接口", "【本地控制接口】只监听本机的 HTTP 接口，供命令行暂停、恢复记录和搜索使用", config_control_api).Click(func() {
+				config_control_api = !config_control_api
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置本地控制接口: %v", config_control_api)}
+				if config_control_api && global_control_server == nil {
+					startControlServer()
+				} else if !config_control_api && global_control_server != nil {
+					global_control_server.Stop()
+					global_control_server = nil
+				}
+			})
+			pasteMenu := menu.AddSubMenuItem("自动粘贴", "【自动粘贴】从菜单、选择窗口或快捷键复制条目后，切换回之前的窗口并自动粘贴。Linux X11 需要 libXtst，Wayland 需要 ydotool，macOS 需要辅助功能权限")
+			for _, entry := range []struct {
+				name string
+				enabled *bool
+			}{
+				{"文本", &config_auto_paste.Text},
+				{"图片", &config_auto_paste.Image},
+				{"文件", &config_auto_paste.Files},
+			} {
+				pasteMenu.AddSubMenuItemCheckbox(entry.name, "", *entry.enabled).Click(func() {
+					*entry.enabled = !*entry.enabled
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置自动粘贴%s: %v", entry.name, *entry.enabled)}
+					updateForegroundWatch()
+				})
+			}
+			hotkeyMenu := menu.AddSubMenuItem("全局快捷键", "【全局快捷键】在任意位置通过快捷键打开历史记录、复制之前的记录，Linux 需要 X11")
+			hotkeyMenu.AddSubMenuItemCheckbox("启用", "", config_hotkey_enable).Click(func() {
+				config_hotkey_enable = !config_hotkey_enable
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置全局快捷键: %v", config_hotkey_enable)}
+				stopHotkeys()
+				if config_hotkey_enable {
+					if err := startHotkeys(); err != nil {
+						reportError(fmt.Sprintf("注册全局快捷键失败: %v", err))
+						config_hotkey_enable = false
+					}
+				}
+			})
+			for _, entry := range []struct {
+				name string
+				tip string
+				key *string
+			}{
+				{"打开历史记录", "", &config_hotkeys.Menu},
+				{"复制第N条记录", "修饰键加数字1-9，复制当前剪贴板内容之前的第N条记录", &config_hotkeys.PasteNth},
+				{"循环复制", "连续按下时依次复制更早的记录，停止3秒后从头开始", &config_hotkeys.Cycle},
+			} {
+				keyMenu := hotkeyMenu.AddSubMenuItem(fmt.Sprintf("%s(当前: %s)", entry.name, Ifel(*entry.key == "", "未设置", *entry.key)), entry.tip)
+				keyMenu.AddSubMenuItem("使用最新剪贴板内容设置", "快捷键格式如 ctrl+shift+v").Click(func() {
+					top := history.GetTop()
+					if top == nil || top.Type != TypeText {
+						reportError("设置快捷键失败: 最新的历史记录不是文本")
+						return
+					}
+					text := strings.ToLower(strings.TrimSpace(string(top.Content)))
+					// 复制第N条记录只设置修饰键，使用一个数字验证格式
+					if _, err := ParseHotkey(text + Ifel(entry.key == &config_hotkeys.PasteNth, "+1", "")); err != nil {
+						reportError(fmt.Sprintf("设置快捷键失败: %v", err))
+						return
+					}
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置%s快捷键: %s", entry.name, text)}
+					*entry.key = text
+					restartHotkeys()
+				})
+				keyMenu.AddSubMenuItem("清除", "").Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("清除%s快捷键", entry.name)}
+					*entry.key = ""
+					restartHotkeys()
+				})
+			}
+			ignoreMenu := menu.AddSubMenuItem("忽略列表", "【忽略列表】来自忽略的应用或匹配忽略规则的内容不会被记录、添加到分组和共享")
+			ignoreMenu.AddSubMenuItem("➕ 添加忽略的应用", "使用最新剪贴板内容作为应用的进程名或窗口类名").Click(func() {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加忽略的应用"}
+				top := history.GetTop()
+				if top == nil || top.Type != TypeText || strings.TrimSpace(string(top.Content)) == "" {
+					reportError("添加忽略的应用失败: 最新的历史记录不是文本")
+					return
+				}
+				app := strings.TrimSpace(string(top.Content))
+				if !slices.Contains(config_ignore_apps, app) {
+					config_ignore_apps = append(config_ignore_apps, app)
+				}
+			})
+			ignoreMenu.AddSubMenuItem("➕ 添加忽略规则", "使用最新剪贴板内容作为正则表达式，匹配的内容不会被记录").Click(func() {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加忽略规则"}
+				top := history.GetTop()
+				if top == nil || top.Type != TypeText || strings.TrimSpace(string(top.Content)) == "" {
+					reportError("添加忽略规则失败: 最新的历史记录不是文本")
+					return
+				}
+				pattern := string(top.Content)
+				if _, err := compileIgnorePattern(pattern); err != nil {
+					reportError(fmt.Sprintf("添加忽略规则失败: %v", err))
+					return
+				}
+				if !slices.Contains(config_ignore_patterns, pattern) {
+					config_ignore_patterns = append(config_ignore_patterns, pattern)
+				}
+			})
+			if len(config_ignore_apps) > 0 || len(config_ignore_patterns) > 0 {
+				removeIgnoreMenu := ignoreMenu.AddSubMenuItem("删除", "点击删除对应的应用或规则")
+				for i, app := range config_ignore_apps {
+					removeIgnoreMenu.AddSubMenuItem("应用: " + app, "").Click(func() {
+						global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("删除忽略的应用: %s", app)}
+						config_ignore_apps = slices.Delete(slices.Clone(config_ignore_apps), i, i+1)
+					})
+				}
+				for i, pattern := range config_ignore_patterns {
+					removeIgnoreMenu.AddSubMenuItem("规则: " + truncateString(pattern, 30), pattern).Click(func() {
+						global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("删除忽略规则: %s", pattern)}
+						config_ignore_patterns = slices.Delete(slices.Clone(config_ignore_patterns), i, i+1)
+					})
+				}
+			}
+			sensitiveMenu := menu.AddSubMenuItem("敏感内容", "")
+			sensitiveMenu.AddSubMenuItemCheckbox("自动识别敏感内容", "【自动识别敏感内容】识别密码、密钥、令牌、银行卡号和密码管理器复制的内容，敏感内容在菜单中隐藏，不会共享和保存到本地", config_sensitive_detect).Click(func() {
+				config_sensitive_detect = !config_sensitive_detect
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置自动识别敏感内容: %v", config_sensitive_detect)}
+			})
+			expireMenu := sensitiveMenu.AddSubMenuItem("自动删除", "【自动删除】敏感内容超过设置的时间后自动删除，默认不删除")
+			for _, minutes := range []uint{0, 1, 5, 30} {
+				expireMenu.AddSubMenuItemCheckbox(Ifel(minutes == 0, "不自动删除", fmt.Sprintf("%d分钟后", minutes)), "", config_sensitive_expire == minutes).Click(func() {
+					config_sensitive_expire = minutes
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置敏感内容过期时间: %d分钟", minutes)}
+				})
+			}
+			orderMenu := menu.AddSubMenuItem("历史记录排序", "【历史记录排序】最常用按从菜单复制的次数排序，常用且最近综合使用次数和最近使用时间排序")
+			for _, o := range history_orders {
+				orderMenu.AddSubMenuItemCheckbox(o.Name, "", config_history_order == o.Order).Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置历史记录排序: %s", o.Name)}
+					config_history_order = o.Order
+				})
+			}
+			pageSizeMenu := menu.AddSubMenuItem(fmt.Sprintf("每页显示条数(当前: %d)", config_menu_page_size), "【每页显示条数】日期分组和分组子菜单中每页显示的条数，超过一页的条目放在更多子菜单中")
+			recentMenu := menu.AddSubMenuItem(fmt.Sprintf("顶部显示条数(当前: %d)", config_menu_recent_count), "【顶部显示条数】菜单顶部直接显示的最近条数，更早的条目按日期分组显示")
+			for _, count := range []uint{5, 10, 20, 30} {
+				recentMenu.AddSubMenuItemCheckbox(fmt.Sprint(count), "", config_menu_recent_count == count).Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置顶部显示条数: %d", count)}
+					config_menu_recent_count = count
+				})
+			}
+			for _, size := range []uint{10, 20, 30, 50, 100} {
+				pageSizeMenu.AddSubMenuItemCheckbox(fmt.Sprint(size), "", config_menu_page_size == size).Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置每页显示条数: %d", size)}
+					config_menu_page_size = size
+				})
+			}
+			addRetentionMenuAction(menu, "历史记录", history, func(policy RetentionPolicy) {
+				config_retention = policy
+			})
+			dedupeMenu := menu.AddSubMenuItem("重复记录", "【重复记录】再次复制已存在的内容时的处理方式，同时作用于历史记录和分组")
+			for _, p := range dedupe_policies {
+				dedupeMenu.AddSubMenuItemCheckbox(p.Name, "", config_dedupe_policy == p.Policy).Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置重复记录处理方式: %s", p.Name)}
+					config_dedupe_policy = p.Policy
+					history.SetDedupePolicy(config_dedupe_policy)
+					for _, group := range groups {
+						group.History.SetDedupePolicy(config_dedupe_policy)
+					}
+				})
+			}
 			shareMenu := menu.AddSubMenuItem("局域网共享","")
 			shareMenu.AddSubMenuItemCheckbox("局域网共享" + IfelFunc(global_history_share_server != nil, func() string { return fmt.Sprintf("(%v)", global_history_share_server.AddrString()) }, func() string { return "" }), "", global_history_share_server != nil).Click(func() {
 				global_log_channel <- LogEntry{Kind: KindInfo, Content: Ifel(global_history_share_server == nil, "启动局域网共享", "关闭局域网共享")}
@@ -581,6 +1862,39 @@ func main() {
 					})
 				}
 			})
+			shareMenu.AddSubMenuItem("设置下载目录" + fmt.Sprintf("(当前: %s)", getDownloadDir()), "【设置下载目录】使用最新剪贴板内容作为接收共享文件的目录").Click(func() {
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: "设置下载目录"}
+				top := history.GetTop()
+				if top == nil || top.Type != TypeText {
+					global_log_channel <- LogEntry{Kind: KindError, Content: "设置下载目录失败: 最新的历史记录不是文本"}
+					return
+				}
+
+				dir := strings.TrimSpace(string(top.Content))
+				if !filepath.IsAbs(dir) {
+					global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("设置下载目录失败: 不是绝对路径: %s", dir)}
+					return
+				}
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("设置下载目录失败: %v", err)}
+					return
+				}
+
+				config_share_download_dir = dir
+			})
+			menu.AddSubMenuItemCheckbox("显示图片缩略图", "", config_menu_thumbnail).Click(func() {
+				config_menu_thumbnail = !config_menu_thumbnail
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置显示图片缩略图: %v", config_menu_thumbnail)}
+			})
+			menu.AddSubMenuItemCheckbox("图片文字识别", "【图片文字识别】在后台识别图片中的文字，识别结果可以搜索和复制，需要安装 " + config_ocr_command, config_ocr_enable).Click(func() {
+				config_ocr_enable = !config_ocr_enable
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置图片文字识别: %v", config_ocr_enable)}
+				if config_ocr_enable {
+					startOcr(history, groups)
+				} else {
+					stopOcr()
+				}
+			})
 			menu.AddSubMenuItemCheckbox("退出时保存日志", "", config_save_log_to_local).Click(func() {
 				config_save_log_to_local = !config_save_log_to_local
 				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置退出时保存日志: %v", config_save_log_to_local)}
@@ -595,62 +1909,129 @@ func main() {
 			}
 		}
 
+		// 解析并应用搜索语句，成功返回true
+		applySearch := func(text string) bool {
+			query, err := parseSearchQuery(text)
+			if err != nil {
+				global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("设置搜索关键词失败: %v", err)}
+				return false
+			}
+
+			global_search_text = text
+			global_search_query = query
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置搜索关键词: %s", global_search_text)}
+			return true
+		}
+
 		addSearchMenuAction := func ()  {
 			global_log_channel <- LogEntry{Kind: KindInfo, Content: "添加`搜索`菜单"}
-			systray.AddMenuItemCheckbox("🔎 搜索" + Ifel(global_search_enable, ":" + global_search_text, ""), "【搜索】会使用剪贴板内的内容进行过滤，再次点击取消搜索", global_search_enable).Click(func() {
+			systray.AddMenuItemCheckbox("🔎 搜索" + Ifel(global_search_enable, ":" + global_search_text, ""), "【搜索】会使用剪贴板内的内容进行过滤，再次点击取消搜索。多个关键词用空格分隔，\"短语\"精确匹配，re:表达式 使用正则，type:image from:remote since:1d 等过滤条件", global_search_enable).Click(func() {
 				global_search_enable = !global_search_enable
 				global_log_channel <- LogEntry{Kind: KindInfo, Content: Ifel(global_search_enable, "启用搜索", "禁用搜索")}
 				if !global_search_enable{
 					global_search_text = ""
+					global_search_query = nil
 					return
 				}
 
 				top := history.GetTop()
-				if top == nil{
+				if top == nil || top.Type == TypeImage {
+					global_search_enable = false
 					return
 				}
 				text := string(top.Content)
 				if text == ""{
+					global_search_enable = false
 					return
 				}
 
-				global_search_text = text
-				global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("设置搜索关键词: %s", global_search_text)}
+				global_search_enable = applySearch(text)
 			})
+
+			if global_search_enable {
+				systray.AddMenuItem("⭐ 保存为过滤器", "【保存为过滤器】将当前搜索语句保存为过滤器").Click(func() {
+					for _, filter := range config_saved_filters {
+						if filter.Query == global_search_text {
+							global_log_channel <- LogEntry{Kind: KindError, Content: "保存过滤器失败: 已经存在相同的过滤器"}
+							return
+						}
+					}
+					config_saved_filters = append(config_saved_filters, SavedFilter{Name: global_search_text, Query: global_search_text})
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("保存过滤器: %s", global_search_text)}
+				})
+			}
+
+			if len(config_saved_filters) == 0 {
+				return
+			}
+			for _, filter := range config_saved_filters {
+				active := global_search_enable && global_search_text == filter.Query
+				systray.AddMenuItemCheckbox("⭐ " + filter.Name, filter.Query, active).Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("%s过滤器: %s", Ifel(active, "取消", "应用"), filter.Name)}
+					if active {
+						global_search_enable = false
+						global_search_text = ""
+						global_search_query = nil
+						return
+					}
+					global_search_enable = applySearch(filter.Query)
+				})
+			}
+			deleteMenu := systray.AddMenuItem("删除过滤器", "")
+			for i, filter := range config_saved_filters {
+				deleteMenu.AddSubMenuItem(filter.Name, filter.Query).Click(func() {
+					global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("删除过滤器: %s", filter.Name)}
+					config_saved_filters = append(config_saved_filters[:i], config_saved_filters[i+1:]...)
+				})
+			}
 		}
 
 		systray.SetOnClick(func(menu systray.IMenu) {
 			global_log_channel <- LogEntry{Kind: KindInfo, Content: "点击托盘图标"}
 
-			global_show_menu_state = Click
-
-			systray.ResetMenu()
-
-			if addHistoryMenuAction() {
-				addSeparator()
-			}
-			addGroupMenuAction()
+			rememberPasteTarget()
+			buildClickMenu()
 
 			global_log_channel <- LogEntry{Kind: KindInfo, Content: "显示菜单"}
-			menu.ShowMenu()
+			if err := menu.ShowMenu(); err != nil {
+				global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("显示菜单失败: %v", err)}
+			}
 		})
 		systray.SetOnRClick(func(menu systray.IMenu) {
 			global_log_channel <- LogEntry{Kind: KindInfo, Content: "右键点击托盘图标"}
 
+			rememberPasteTarget()
 			global_show_menu_state = RClick
 
 			systray.ResetMenu()
 
+			pinnedSnippet := addPinnedSnippetMenuAction()
+			if addPinnedMenuAction() || pinnedSnippet {
+				addSeparator()
+			}
 			if addHistoryMenuAction() {
 				addSeparator()
 			}
-			addCleanHistoryMenuCmd()
-			addSeparator()
+			lock := addLockMenuAction()
+			if addPauseMenuAction() || lock {
+				addSeparator()
+			}
+			if !global_locked {
+				addCleanHistoryMenuCmd()
+				addSeparator()
+			}
 			if (addGroupMenuAction()) {
 				addSeparator()
 			}
-			addCreateGroupMenuCmd()
+			if !global_locked {
+				addCreateGroupMenuCmd()
+				addSeparator()
+			}
+			addSnippetMenuAction()
 			addSeparator()
+			if !global_locked {
+				addPickerMenuCmd()
+			}
 			addSearchMenuAction()
 			addSeparator()
 			addConfigMenuAction()
@@ -658,9 +2039,17 @@ func main() {
 			addQuitMenuCmd()
 
 			global_log_channel <- LogEntry{Kind: KindInfo, Content: "显示菜单"}
-			menu.ShowMenu()
+			if err := menu.ShowMenu(); err != nil {
+				global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("显示菜单失败: %v", err)}
+			}
 		})
 	}, func() {
+		if global_control_server != nil {
+			global_control_server.Stop()
+		}
+		if global_stop_hotkeys != nil {
+			global_stop_hotkeys()
+		}
 		def()
 		cacheToLocal()
 		logToLocal()
@@ -0,0 +1,70 @@
+package main
+
+import "time"
+
+// 按日期分组的历史记录
+type DateBucket struct {
+	Name  string
+	Items []*ClipItem
+}
+
+// 每次打开菜单时历史记录子菜单中最多添加的条目数，超出的条目通过搜索查找
+// 右键菜单中每个条目还有复制、固定等子菜单，条数更少
+const (
+	const_menu_max_items        = 200
+	const_menu_max_action_items = 50
+)
+
+// 本次菜单中还可以添加的条目数
+func menuItemBudget(state ShowMenuState) int {
+	return Ifel(state == RClick, const_menu_max_action_items, const_menu_max_items)
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// 将条目按日期分组，条目按从新到旧的顺序传入，只返回有条目的分组
+func bucketItemsByDate(items []*ClipItem, now time.Time) []DateBucket {
+	today := startOfDay(now)
+	// 每周从周一开始
+	week := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+
+	buckets := []DateBucket{
+		{Name: "今天"},
+		{Name: "昨天"},
+		{Name: "本周"},
+		{Name: "上周"},
+		{Name: "本月"},
+		{Name: "上个月"},
+		{Name: "更早"},
+	}
+	starts := []time.Time{
+		today,
+		today.AddDate(0, 0, -1),
+		week,
+		week.AddDate(0, 0, -7),
+		month,
+		month.AddDate(0, -1, 0),
+		{},
+	}
+
+	for _, item := range items {
+		for i, start := range starts {
+			if !item.Time.Before(start) {
+				buckets[i].Items = append(buckets[i].Items, item)
+				break
+			}
+		}
+	}
+
+	result := []DateBucket{}
+	for _, bucket := range buckets {
+		if len(bucket.Items) > 0 {
+			result = append(result, bucket)
+		}
+	}
+	return result
+}
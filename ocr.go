@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 后台识别任务，关闭识别时为空
+var global_ocr_worker atomic.Pointer[OcrWorker]
+
+// 图片文字识别引擎
+type OcrEngine interface {
+	Name() string
+	Recognize(image []byte) (string, error)
+}
+
+// 可用的识别引擎，按名称创建
+var ocr_engines = map[string]func() OcrEngine{
+	"tesseract": func() OcrEngine {
+		return &TesseractEngine{Command: config_ocr_command, Language: config_ocr_language}
+	},
+}
+
+func NewOcrEngine(name string) (OcrEngine, error) {
+	create, ok := ocr_engines[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的识别引擎: %s", name)
+	}
+	return create(), nil
+}
+
+// 调用本地 tesseract 命令识别，不需要联网
+type TesseractEngine struct {
+	Command  string
+	Language string
+}
+
+func (e *TesseractEngine) Name() string {
+	return "tesseract"
+}
+
+func (e *TesseractEngine) Recognize(image []byte) (string, error) {
+	args := []string{"stdin", "stdout"}
+	if e.Language != "" {
+		args = append(args, "-l", e.Language)
+	}
+
+	cmd := exec.Command(e.Command, args...)
+	cmd.Stdin = bytes.NewReader(image)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// 在后台依次识别图片，结果只保存在按内容哈希索引的缓存中，不修改条目，避免与菜单、搜索和保存同时读写
+type OcrWorker struct {
+	engine OcrEngine
+	queue  chan *ClipItem
+	done   chan struct{}
+	stop   sync.Once
+	cache  map[string]string
+	mu     sync.Mutex
+}
+
+func NewOcrWorker(engine OcrEngine) *OcrWorker {
+	w := &OcrWorker{
+		engine: engine,
+		queue:  make(chan *ClipItem, 100),
+		done:   make(chan struct{}),
+		cache:  make(map[string]string),
+	}
+	go w.run()
+	return w
+}
+
+// 识别结果，尚未识别时ok为false
+func (w *OcrWorker) Text(hash string) (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	text, ok := w.cache[hash]
+	return text, ok
+}
+
+func (w *OcrWorker) Submit(item *ClipItem) {
+	if item.Type != TypeImage || item.Ocr != "" {
+		return
+	}
+	if _, ok := w.Text(item.Hash); ok {
+		return
+	}
+
+	select {
+	case <-w.done:
+	case w.queue <- item:
+	default:
+		global_log_channel <- LogEntry{Kind: KindError, Content: "文字识别队列已满，跳过图片"}
+	}
+}
+
+func (w *OcrWorker) run() {
+	for {
+		var item *ClipItem
+		select {
+		case <-w.done:
+			return
+		case item = <-w.queue:
+		}
+
+		if _, ok := w.Text(item.Hash); ok {
+			continue
+		}
+		text, err := w.engine.Recognize(item.Content)
+		if err != nil {
+			global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("文字识别失败(%s): %v", w.engine.Name(), err)}
+		} else {
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("文字识别完成: %s", truncateString(text, 40))}
+		}
+
+		// 失败的图片同样缓存，避免重复识别
+		w.mu.Lock()
+		w.cache[item.Hash] = text
+		w.mu.Unlock()
+		if text != "" {
+			global_search_index.SetText(item.Hash, text)
+		}
+	}
+}
+
+// 停止识别，队列不关闭，停止后提交的图片直接忽略
+func (w *OcrWorker) Stop() {
+	w.stop.Do(func() {
+		close(w.done)
+	})
+}
+
+// 图片识别出的文字，优先使用加载时保存的结果，其次为后台识别的结果
+func ocrText(item *ClipItem) string {
+	if item.Type != TypeImage {
+		return ""
+	}
+	if item.Ocr != "" {
+		return item.Ocr
+	}
+	if w := global_ocr_worker.Load(); w != nil {
+		text, _ := w.Text(item.Hash)
+		return text
+	}
+	return ""
+}
+
+// 保存前将后台识别的结果写入条目的副本，不修改原条目
+func withOcrText(items []*ClipItem) []*ClipItem {
+	result := make([]*ClipItem, len(items))
+	for i, item := range items {
+		result[i] = item
+		if item.Type == TypeImage && item.Ocr == "" {
+			if text := ocrText(item); text != "" {
+				result[i] = item.Clone()
+				result[i].Ocr = text
+			}
+		}
+	}
+	return result
+}
+
+func stopOcr() {
+	if w := global_ocr_worker.Swap(nil); w != nil {
+		w.Stop()
+	}
+}
+
+// 启动后台识别，并识别已有的图片
+func startOcr(history *History, groups map[string]*Group) {
+	engine, err := NewOcrEngine(config_ocr_engine)
+	if err != nil {
+		global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("启动文字识别失败: %v", err)}
+		return
+	}
+	worker := global_ocr_worker.Load()
+	if worker == nil {
+		worker = NewOcrWorker(engine)
+		if !global_ocr_worker.CompareAndSwap(nil, worker) {
+			worker.Stop()
+			worker = global_ocr_worker.Load()
+		}
+	}
+
+	for _, item := range history.GetAll() {
+		worker.Submit(item)
+	}
+	for _, group := range groups {
+		for _, item := range group.History.GetAll() {
+			worker.Submit(item)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// 写入剪贴板后等待菜单或选择窗口关闭再粘贴
+	const_paste_delay = 200 * time.Millisecond
+	// 等待写入剪贴板的最长时间，超时后不粘贴，避免粘贴之前的内容
+	const_paste_timeout = 5 * time.Second
+)
+
+// 写入剪贴板的协程写入条目后通知等待粘贴的协程
+var global_write_waiter = &writeWaiter{waiting: make(map[*ClipItem][]chan struct{})}
+
+// 自动粘贴的目标窗口，由点击托盘、快捷键和 Windows 的前台窗口钩子同时读写
+var global_paste_target atomic.Pointer[pasteTarget]
+
+type writeWaiter struct {
+	waiting map[*ClipItem][]chan struct{}
+	mu      sync.Mutex
+}
+
+// 在发送到写入协程之前调用，返回的通道在条目写入剪贴板后关闭
+func (w *writeWaiter) Wait(item *ClipItem) <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	done := make(chan struct{})
+	w.waiting[item] = append(w.waiting[item], done)
+	return done
+}
+
+func (w *writeWaiter) Done(item *ClipItem) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, done := range w.waiting[item] {
+		close(done)
+	}
+	delete(w.waiting, item)
+}
+
+// 放弃等待，超时后调用避免通道一直保留
+func (w *writeWaiter) Cancel(item *ClipItem, done <-chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.waiting[item] = slices.DeleteFunc(w.waiting[item], func(c chan struct{}) bool { return c == done })
+	if len(w.waiting[item]) == 0 {
+		delete(w.waiting, item)
+	}
+}
+
+// 自动粘贴设置，按条目类型分别开启
+type AutoPasteConfig struct {
+	Text  bool `json:"text"`
+	Image bool `json:"image"`
+	Files bool `json:"files"`
+}
+
+func (c AutoPasteConfig) Enabled(t ItemType) bool {
+	switch t {
+	case TypeText:
+		return c.Text
+	case TypeImage:
+		return c.Image
+	case TypeFiles:
+		return c.Files
+	}
+	return false
+}
+
+func (c AutoPasteConfig) Any() bool {
+	return c.Text || c.Image || c.Files
+}
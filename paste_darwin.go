@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 粘贴的目标应用，为空时粘贴到当前活动应用
+type pasteTarget struct {
+	app string
+}
+
+// 获取当前活动应用，忽略本程序
+func activeWindow() (pasteTarget, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	data, err := exec.CommandContext(ctx, "osascript", "-e", `tell application "System Events" to get name of first application process whose frontmost is true`).Output()
+	app := strings.TrimSpace(string(data))
+	if err != nil || app == "" || strings.EqualFold(app, filepath.Base(os.Args[0])) {
+		return pasteTarget{}, false
+	}
+	return pasteTarget{app: app}, true
+}
+
+// 通过 System Events 激活目标应用并模拟 Command+V，需要在系统设置中授予辅助功能权限
+func pasteTo(target pasteTarget, left int) error {
+	script := `tell application "System Events"` + "\n"
+	if target.app != "" {
+		script += fmt.Sprintf("set frontmost of first application process whose name is %q to true\ndelay 0.1\n", target.app)
+	}
+	// 等待快捷键的修饰键松开
+	script += "delay 0.2\nkeystroke \"v\" using command down\n"
+	if left > 0 {
+		script += fmt.Sprintf("repeat %d times\nkey code 123\nend repeat\n", left)
+	}
+	script += "end tell"
+
+	if output, err := exec.Command("osascript", "-e", script).CombinedOutput(); err != nil {
+		return fmt.Errorf("osascript 执行失败: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
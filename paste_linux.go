@@ -0,0 +1,204 @@
+package main
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <unistd.h>
+#include <X11/Xlib.h>
+#include <X11/Xatom.h>
+#include <X11/keysym.h>
+
+// 运行时加载 libX11 和 libXtst，缺少 libXtst 时无法模拟按键
+static void* paste_x11 = NULL;
+static void* paste_xtst = NULL;
+static Display* (*pXOpenDisplay)(const char*);
+static int (*pXCloseDisplay)(Display*);
+static Window (*pXDefaultRootWindow)(Display*);
+static Atom (*pXInternAtom)(Display*, const char*, Bool);
+static int (*pXGetWindowProperty)(Display*, Window, Atom, long, long, Bool, Atom, Atom*, int*, unsigned long*, unsigned long*, unsigned char**);
+static int (*pXFree)(void*);
+static Status (*pXSendEvent)(Display*, Window, Bool, long, XEvent*);
+static int (*pXSync)(Display*, Bool);
+static KeyCode (*pXKeysymToKeycode)(Display*, KeySym);
+static Bool (*pXQueryPointer)(Display*, Window, Window*, Window*, int*, int*, int*, int*, unsigned int*);
+static int (*pXTestFakeKeyEvent)(Display*, unsigned int, Bool, unsigned long);
+
+// 返回 0 成功，-1 缺少 libX11，-2 缺少 libXtst，-3 无法连接显示服务
+static int paste_open(Display** display) {
+	if (paste_x11 == NULL) {
+		paste_x11 = dlopen("libX11.so", RTLD_LAZY);
+		if (paste_x11 == NULL) {
+			paste_x11 = dlopen("libX11.so.6", RTLD_LAZY);
+		}
+		if (paste_x11 == NULL) {
+			return -1;
+		}
+		pXOpenDisplay = dlsym(paste_x11, "XOpenDisplay");
+		pXCloseDisplay = dlsym(paste_x11, "XCloseDisplay");
+		pXDefaultRootWindow = dlsym(paste_x11, "XDefaultRootWindow");
+		pXInternAtom = dlsym(paste_x11, "XInternAtom");
+		pXGetWindowProperty = dlsym(paste_x11, "XGetWindowProperty");
+		pXFree = dlsym(paste_x11, "XFree");
+		pXSendEvent = dlsym(paste_x11, "XSendEvent");
+		pXSync = dlsym(paste_x11, "XSync");
+		pXKeysymToKeycode = dlsym(paste_x11, "XKeysymToKeycode");
+		pXQueryPointer = dlsym(paste_x11, "XQueryPointer");
+	}
+	if (paste_xtst == NULL) {
+		paste_xtst = dlopen("libXtst.so", RTLD_LAZY);
+		if (paste_xtst == NULL) {
+			paste_xtst = dlopen("libXtst.so.6", RTLD_LAZY);
+		}
+		if (paste_xtst == NULL) {
+			return -2;
+		}
+		pXTestFakeKeyEvent = dlsym(paste_xtst, "XTestFakeKeyEvent");
+	}
+	*display = pXOpenDisplay(NULL);
+	return *display == NULL ? -3 : 0;
+}
+
+static void paste_close(Display* d) {
+	pXCloseDisplay(d);
+}
+
+// 读取窗口管理器记录的活动窗口
+static unsigned long paste_active_window(Display* d) {
+	Atom prop = pXInternAtom(d, "_NET_ACTIVE_WINDOW", True);
+	if (prop == None) {
+		return 0;
+	}
+	Atom type;
+	int format;
+	unsigned long count, after;
+	unsigned char* data = NULL;
+	if (pXGetWindowProperty(d, pXDefaultRootWindow(d), prop, 0, 1, False, XA_WINDOW, &type, &format, &count, &after, &data) != Success || data == NULL) {
+		return 0;
+	}
+	unsigned long window = count > 0 ? *(unsigned long*)data : 0;
+	pXFree(data);
+	return window;
+}
+
+// 请求窗口管理器激活窗口
+static void paste_activate(Display* d, unsigned long window) {
+	XEvent ev = {0};
+	ev.xclient.type = ClientMessage;
+	ev.xclient.window = window;
+	ev.xclient.message_type = pXInternAtom(d, "_NET_ACTIVE_WINDOW", False);
+	ev.xclient.format = 32;
+	ev.xclient.data.l[0] = 2;
+	ev.xclient.data.l[1] = CurrentTime;
+	pXSendEvent(d, pXDefaultRootWindow(d), False, SubstructureRedirectMask | SubstructureNotifyMask, &ev);
+	pXSync(d, False);
+}
+
+// 等待修饰键松开，避免快捷键的修饰键和粘贴组合在一起
+static void paste_wait_modifiers(Display* d, int timeout_ms) {
+	Window root, child;
+	int rx, ry, wx, wy;
+	unsigned int mask;
+	for (int elapsed = 0; elapsed < timeout_ms; elapsed += 20) {
+		pXQueryPointer(d, pXDefaultRootWindow(d), &root, &child, &rx, &ry, &wx, &wy, &mask);
+		if ((mask & (ShiftMask | ControlMask | Mod1Mask | Mod4Mask)) == 0) {
+			return;
+		}
+		usleep(20000);
+	}
+}
+
+static void paste_key(Display* d, KeySym sym, Bool press) {
+	pXTestFakeKeyEvent(d, pXKeysymToKeycode(d, sym), press, CurrentTime);
+}
+
+// 模拟 Ctrl+V，之后按 left 次左方向键
+static void paste_send(Display* d, int left) {
+	paste_key(d, XK_Control_L, True);
+	paste_key(d, XK_v, True);
+	paste_key(d, XK_v, False);
+	paste_key(d, XK_Control_L, False);
+	for (int i = 0; i < left; i++) {
+		paste_key(d, XK_Left, True);
+		paste_key(d, XK_Left, False);
+	}
+	pXSync(d, False);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// 粘贴的目标窗口，为 0 时粘贴到当前活动窗口
+type pasteTarget struct {
+	window C.ulong
+}
+
+var paste_open_errors = map[C.int]string{
+	-1: "无法加载 libX11",
+	-2: "无法加载 libXtst，请安装 libXtst",
+	-3: "无法连接到 X11 显示服务",
+}
+
+func isWayland() bool {
+	return os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+// 获取当前活动窗口，Wayland 下无法获取
+func activeWindow() (pasteTarget, bool) {
+	if isWayland() {
+		return pasteTarget{}, false
+	}
+	var display *C.Display
+	if C.paste_open(&display) != 0 {
+		return pasteTarget{}, false
+	}
+	defer C.paste_close(display)
+
+	window := C.paste_active_window(display)
+	return pasteTarget{window: window}, window != 0
+}
+
+// 激活目标窗口并模拟粘贴，X11 通过 XTest 模拟按键，Wayland 通过 ydotool 使用 uinput 模拟按键
+func pasteTo(target pasteTarget, left int) error {
+	if isWayland() {
+		return ydotoolPaste(left)
+	}
+
+	var display *C.Display
+	if code := C.paste_open(&display); code != 0 {
+		return errors.New(paste_open_errors[code])
+	}
+	defer C.paste_close(display)
+
+	if target.window != 0 && C.paste_active_window(display) != target.window {
+		C.paste_activate(display, target.window)
+		time.Sleep(100 * time.Millisecond)
+	}
+	C.paste_wait_modifiers(display, 1000)
+	C.paste_send(display, C.int(left))
+	return nil
+}
+
+// ydotool 使用 Linux 输入事件码，29 为左 Ctrl，47 为 V，105 为左方向键
+func ydotoolPaste(left int) error {
+	if _, err := exec.LookPath("ydotool"); err != nil {
+		return errors.New("Wayland 下自动粘贴需要安装 ydotool 并启动 ydotoold")
+	}
+	// 无法查询修饰键状态，等待快捷键松开
+	time.Sleep(300 * time.Millisecond)
+	args := []string{"key", "29:1", "47:1", "47:0", "29:0"}
+	for i := 0; i < left; i++ {
+		args = append(args, "105:1", "105:0")
+	}
+	if output, err := exec.Command("ydotool", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("ydotool 执行失败: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
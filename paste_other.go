@@ -0,0 +1,16 @@
+//go:build !linux && !windows && !darwin
+
+package main
+
+import "errors"
+
+type pasteTarget struct{}
+
+func activeWindow() (pasteTarget, bool) {
+	return pasteTarget{}, false
+}
+
+// 其他平台暂不支持自动粘贴
+func pasteTo(target pasteTarget, left int) error {
+	return errors.New("当前平台不支持自动粘贴")
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	procGetForegroundWindow      = user32.NewProc("GetForegroundWindow")
+	procSetForegroundWindow      = user32.NewProc("SetForegroundWindow")
+	procGetWindowThreadProcessId = user32.NewProc("GetWindowThreadProcessId")
+	procGetClassNameW            = user32.NewProc("GetClassNameW")
+	procGetAsyncKeyState         = user32.NewProc("GetAsyncKeyState")
+	procKeybdEvent               = user32.NewProc("keybd_event")
+	procSetWinEventHook          = user32.NewProc("SetWinEventHook")
+	procUnhookWinEvent           = user32.NewProc("UnhookWinEvent")
+)
+
+const (
+	win_vk_shift     = 0x10
+	win_vk_control   = 0x11
+	win_vk_menu      = 0x12
+	win_vk_lwin      = 0x5B
+	win_vk_rwin      = 0x5C
+	win_vk_left      = 0x25
+	win_vk_v         = 0x56
+	win_keyeventf_up = 0x0002
+
+	win_event_system_foreground = 0x0003
+	win_winevent_outofcontext   = 0x0000
+	win_winevent_skipownprocess = 0x0002
+)
+
+// 粘贴的目标窗口，为 0 时粘贴到当前活动窗口
+type pasteTarget struct {
+	hwnd uintptr
+}
+
+// 获取当前活动窗口，忽略任务栏和本程序的窗口
+func activeWindow() (pasteTarget, bool) {
+	hwnd, _, _ := procGetForegroundWindow.Call()
+	return windowTarget(hwnd)
+}
+
+func windowTarget(hwnd uintptr) (pasteTarget, bool) {
+	if hwnd == 0 {
+		return pasteTarget{}, false
+	}
+
+	var pid uint32
+	procGetWindowThreadProcessId.Call(hwnd, uintptr(unsafe.Pointer(&pid)))
+	if int(pid) == os.Getpid() {
+		return pasteTarget{}, false
+	}
+	class := make([]uint16, 64)
+	procGetClassNameW.Call(hwnd, uintptr(unsafe.Pointer(&class[0])), uintptr(len(class)))
+	switch syscall.UTF16ToString(class) {
+	case "Shell_TrayWnd", "NotifyIconOverflowWindow", "Shell_SecondaryTrayWnd":
+		return pasteTarget{}, false
+	}
+	return pasteTarget{hwnd: hwnd}, true
+}
+
+// 前台窗口变化时的回调，回调只能创建有限个，所有钩子共用
+var foreground_callback func(pasteTarget)
+var foreground_event_proc = syscall.NewCallback(func(hook, event, hwnd, idObject, idChild, thread, eventTime uintptr) uintptr {
+	if target, ok := windowTarget(hwnd); ok && foreground_callback != nil {
+		foreground_callback(target)
+	}
+	return 0
+})
+
+// 通过 SetWinEventHook 监听前台窗口变化，点击托盘图标时任务栏已经成为活动窗口，只能提前记录
+// 钩子的回调在安装钩子的线程的消息循环中执行，返回的停止函数等待钩子卸载后才返回
+func watchForeground(callback func(pasteTarget)) (func(), error) {
+	result := make(chan error, 1)
+	threadId := make(chan uintptr, 1)
+	done := make(chan struct{})
+	foreground_callback = callback
+
+	go func() {
+		defer close(done)
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		tid, _, _ := procGetCurrentThreadId.Call()
+		hook, _, err := procSetWinEventHook.Call(win_event_system_foreground, win_event_system_foreground, 0, foreground_event_proc, 0, 0, win_winevent_outofcontext|win_winevent_skipownprocess)
+		if hook == 0 {
+			result <- fmt.Errorf("监听活动窗口失败: %v", err)
+			return
+		}
+		defer procUnhookWinEvent.Call(hook)
+		if target, ok := activeWindow(); ok {
+			callback(target)
+		}
+		threadId <- tid
+		result <- nil
+
+		var msg winMsg
+		for {
+			r, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+			if int32(r) <= 0 {
+				break
+			}
+		}
+	}()
+
+	if err := <-result; err != nil {
+		return nil, err
+	}
+	tid := <-threadId
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			procPostThreadMessageW.Call(tid, win_wm_quit, 0, 0)
+			<-done
+		})
+	}, nil
+}
+
+func keyPressed(vk uintptr) bool {
+	state, _, _ := procGetAsyncKeyState.Call(vk)
+	return state&0x8000 != 0
+}
+
+func sendKey(vk uintptr, up bool) {
+	procKeybdEvent.Call(vk, 0, Ifel(up, uintptr(win_keyeventf_up), 0), 0)
+}
+
+// 激活目标窗口并模拟 Ctrl+V
+func pasteTo(target pasteTarget, left int) error {
+	if target.hwnd != 0 {
+		current, _, _ := procGetForegroundWindow.Call()
+		if current != target.hwnd {
+			if r, _, _ := procSetForegroundWindow.Call(target.hwnd); r == 0 {
+				return errors.New("无法切换到之前的窗口")
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	// 等待快捷键的修饰键松开
+	for i := 0; i < 50; i++ {
+		if !keyPressed(win_vk_shift) && !keyPressed(win_vk_control) && !keyPressed(win_vk_menu) && !keyPressed(win_vk_lwin) && !keyPressed(win_vk_rwin) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	sendKey(win_vk_control, false)
+	sendKey(win_vk_v, false)
+	sendKey(win_vk_v, true)
+	sendKey(win_vk_control, true)
+	for i := 0; i < left; i++ {
+		sendKey(win_vk_left, false)
+		sendKey(win_vk_left, true)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 暂停记录的状态，暂停期间剪贴板内容不会被记录
+type PauseState struct {
+	paused bool
+	until  time.Time // 为零时表示直到手动恢复
+	timer  *time.Timer
+	// 每次暂停或恢复时递增，定时器触发时用于判断暂停状态是否已被再次修改
+	generation uint64
+	onChange   func(paused bool, until time.Time)
+	mu         sync.Mutex
+}
+
+func NewPauseState() *PauseState {
+	return &PauseState{}
+}
+
+// 暂停记录，duration 为 0 时直到手动恢复
+func (p *PauseState) Pause(duration time.Duration) {
+	p.mu.Lock()
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.generation++
+	p.paused = true
+	p.until = time.Time{}
+	if duration > 0 {
+		// 已触发的定时器无法停止，再次暂停后旧定时器不会恢复记录
+		generation := p.generation
+		p.until = time.Now().Add(duration)
+		p.timer = time.AfterFunc(duration, func() {
+			p.resume(&generation, "暂停时间已到，自动恢复记录")
+		})
+	}
+	paused, until, onChange := p.paused, p.until, p.onChange
+	p.mu.Unlock()
+
+	global_log_channel <- LogEntry{Kind: KindInfo, Content: formatPauseStatus(paused, until)}
+	if onChange != nil {
+		onChange(paused, until)
+	}
+}
+
+func (p *PauseState) Resume() {
+	p.resume(nil, "恢复记录")
+}
+
+// 恢复记录，generation 不为空时只在暂停状态未被再次修改时恢复
+func (p *PauseState) resume(generation *uint64, message string) {
+	p.mu.Lock()
+	if generation != nil && *generation != p.generation {
+		p.mu.Unlock()
+		return
+	}
+	p.generation++
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.paused = false
+	p.until = time.Time{}
+	onChange := p.onChange
+	p.mu.Unlock()
+
+	global_log_channel <- LogEntry{Kind: KindInfo, Content: message}
+	if onChange != nil {
+		onChange(false, time.Time{})
+	}
+}
+
+func (p *PauseState) Status() (bool, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.paused, p.until
+}
+
+func (p *PauseState) Paused() bool {
+	paused, _ := p.Status()
+	return paused
+}
+
+func (p *PauseState) OnChange(callback func(paused bool, until time.Time)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onChange = callback
+}
+
+func formatPauseStatus(paused bool, until time.Time) string {
+	if !paused {
+		return "正在记录"
+	}
+	if until.IsZero() {
+		return "已暂停记录"
+	}
+	return fmt.Sprintf("已暂停记录，%s 自动恢复", until.Format("15:04"))
+}
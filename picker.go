@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// 选择窗口最多显示的搜索结果条数
+const const_picker_max_results = 500
+
+// 列表中每个条目最多显示的字符数，较长的内容只取开头，避免每次绘制都处理全部内容
+const const_picker_label_runes = 200
+
+var picker_type_names = map[ItemType]string{
+	TypeText:  "文本",
+	TypeImage: "图片",
+	TypeFiles: "文件",
+}
+
+// 选择窗口的标签页，对应历史记录或分组
+type PickerTab struct {
+	Name    string
+	History *History
+}
+
+// 选择窗口的状态，与界面无关，键盘和鼠标操作都转换为对状态的修改
+type Picker struct {
+	tabs     []PickerTab
+	parse    func(text string) (*SearchQuery, error)
+	onSelect func(item *ClipItem, tab PickerTab)
+
+	tab      int
+	query    string
+	queryErr error
+	items    []*ClipItem
+	results  []*ClipItem
+	selected int
+}
+
+func NewPicker(tabs []PickerTab, parse func(text string) (*SearchQuery, error), onSelect func(item *ClipItem, tab PickerTab)) *Picker {
+	p := &Picker{
+		tabs:     tabs,
+		parse:    parse,
+		onSelect: onSelect,
+	}
+	p.loadTab()
+	return p
+}
+
+// 重新读取当前标签页的条目
+func (p *Picker) loadTab() {
+	p.items = nil
+	if p.tab < len(p.tabs) {
+		p.items = orderItems(p.tabs[p.tab].History.GetAll(), config_history_order)
+	}
+	p.filter()
+}
+
+// 按搜索语句过滤当前标签页，搜索语句有误时保留上一次的结果
+func (p *Picker) filter() {
+	p.queryErr = nil
+	p.selected = 0
+	if strings.TrimSpace(p.query) == "" {
+		p.results = p.items[:min(len(p.items), const_picker_max_results)]
+		return
+	}
+
+	query, err := p.parse(p.query)
+	if err != nil {
+		p.queryErr = err
+		return
+	}
+	group := ""
+	if p.tab > 0 {
+		group = p.tabs[p.tab].Name
+	}
+	results := SearchItems(query, p.items, group)
+	p.results = results[:min(len(results), const_picker_max_results)]
+}
+
+func (p *Picker) Tabs() []PickerTab {
+	return p.tabs
+}
+
+func (p *Picker) Tab() int {
+	return p.tab
+}
+
+// 切换标签页，delta为正时向后切换，首尾循环
+func (p *Picker) SwitchTab(delta int) {
+	if len(p.tabs) == 0 {
+		return
+	}
+	p.tab = ((p.tab+delta)%len(p.tabs) + len(p.tabs)) % len(p.tabs)
+	p.loadTab()
+}
+
+func (p *Picker) Query() string {
+	return p.query
+}
+
+func (p *Picker) QueryError() error {
+	return p.queryErr
+}
+
+func (p *Picker) SetQuery(query string) {
+	if query == p.query {
+		return
+	}
+	p.query = query
+	p.filter()
+}
+
+// 输入一个字符
+func (p *Picker) Type(r rune) {
+	p.SetQuery(p.query + string(r))
+}
+
+// 删除最后一个字符
+func (p *Picker) Backspace() {
+	_, size := utf8.DecodeLastRuneInString(p.query)
+	p.SetQuery(p.query[:len(p.query)-size])
+}
+
+func (p *Picker) Results() []*ClipItem {
+	return p.results
+}
+
+func (p *Picker) SelectedIndex() int {
+	return p.selected
+}
+
+// 移动选中的条目，超出范围时停在首尾
+func (p *Picker) Move(delta int) {
+	p.Select(p.selected + delta)
+}
+
+func (p *Picker) Select(index int) {
+	p.selected = max(0, min(index, len(p.results)-1))
+}
+
+func (p *Picker) Selected() *ClipItem {
+	if p.selected < 0 || p.selected >= len(p.results) {
+		return nil
+	}
+	return p.results[p.selected]
+}
+
+// 复制选中的条目，没有条目时返回false
+func (p *Picker) Confirm() bool {
+	item := p.Selected()
+	if item == nil {
+		return false
+	}
+	p.onSelect(item, p.tabs[p.tab])
+	return true
+}
+
+// 内容开头的若干个字符
+func firstRunes(content []byte, n int) string {
+	end := 0
+	for ; n > 0 && end < len(content); n-- {
+		_, size := utf8.DecodeRune(content[end:])
+		end += size
+	}
+	return string(content[:end])
+}
+
+// 选择窗口中条目的单行文字，不使用菜单中的图标，避免字体缺少表情符号
+func pickerLabel(item *ClipItem) string {
+	var text string
+	switch item.Type {
+	case TypeText:
+		content := firstRunes(item.Content, const_picker_label_runes)
+		if item.Sensitive {
+			text = "[敏感] " + maskSensitive(content)
+		} else {
+			text = strings.Join(strings.Fields(content), " ")
+		}
+
+	case TypeImage:
+		if info, ok := getImageInfo(item.Content); ok {
+			text = fmt.Sprintf("[图片] %d×%d %s %s", info.Width, info.Height, info.Format, formatSize(info.Size))
+		} else {
+			text = "[图片]"
+		}
+
+	case TypeFiles:
+		paths := strings.Split(string(item.Content), "\n")
+		text = fmt.Sprintf("[文件 %d] %s", len(paths), filepath.Base(paths[0]))
+	}
+
+	return fmt.Sprintf("%s%s %s%s", Ifel(item.Pinned, "[固定] ", ""), item.Time.Format("01-02 15:04"), Ifel(item.From == FromRemote, "[R] ", ""), text)
+}
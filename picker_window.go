@@ -0,0 +1,480 @@
+//go:build !darwin
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/exp/shiny/driver"
+	"golang.org/x/exp/shiny/screen"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/lifecycle"
+	"golang.org/x/mobile/event/mouse"
+	"golang.org/x/mobile/event/paint"
+	"golang.org/x/mobile/event/size"
+)
+
+var (
+	picker_screen_once sync.Once
+	picker_screen      screen.Screen
+	picker_face_once   sync.Once
+	picker_face        font.Face
+	picker_opened      atomic.Bool
+)
+
+var (
+	picker_color_background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	picker_color_bar        = color.RGBA{0xf2, 0xf2, 0xf2, 0xff}
+	picker_color_selected   = color.RGBA{0xcc, 0xe4, 0xff, 0xff}
+	picker_color_border     = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+	picker_color_text       = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	picker_color_hint       = color.RGBA{0x88, 0x88, 0x88, 0xff}
+	picker_color_error      = color.RGBA{0xd0, 0x30, 0x30, 0xff}
+)
+
+// 常见的包含中文的系统字体，按顺序使用第一个存在的
+var picker_font_paths = map[string][]string{
+	"linux": {
+		"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",
+		"/usr/share/fonts/noto-cjk/NotoSansCJK-Regular.ttc",
+		"/usr/share/fonts/google-noto-cjk/NotoSansCJK-Regular.ttc",
+		"/usr/share/fonts/truetype/wqy/wqy-microhei.ttc",
+		"/usr/share/fonts/wenquanyi/wqy-microhei/wqy-microhei.ttc",
+		"/usr/share/fonts/truetype/droid/DroidSansFallbackFull.ttf",
+		"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	},
+	"windows": {
+		`C:\Windows\Fonts\msyh.ttc`,
+		`C:\Windows\Fonts\simhei.ttf`,
+		`C:\Windows\Fonts\segoeui.ttf`,
+	},
+}
+
+// 显示服务只初始化一次，之后的窗口共用
+func pickerScreen() screen.Screen {
+	picker_screen_once.Do(func() {
+		ready := make(chan screen.Screen)
+		go driver.Main(func(s screen.Screen) {
+			ready <- s
+			select {}
+		})
+		picker_screen = <-ready
+	})
+	return picker_screen
+}
+
+// 加载字体，找不到字体时使用只支持英文的内置字体
+func pickerFace() font.Face {
+	picker_face_once.Do(func() {
+		picker_face = basicfont.Face7x13
+		paths := picker_font_paths[runtime.GOOS]
+		if config_picker_font != "" {
+			paths = append([]string{config_picker_font}, paths...)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			var f *opentype.Font
+			if strings.HasSuffix(strings.ToLower(path), ".ttc") {
+				var collection *opentype.Collection
+				if collection, err = opentype.ParseCollection(data); err == nil {
+					f, err = collection.Font(0)
+				}
+			} else {
+				f, err = opentype.Parse(data)
+			}
+			if err != nil {
+				global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("加载字体%s失败: %v", path, err)}
+				continue
+			}
+			face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: 10.5, DPI: 96, Hinting: font.HintingFull})
+			if err != nil {
+				continue
+			}
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("选择窗口使用字体: %s", path)}
+			picker_face = face
+			return
+		}
+		global_log_channel <- LogEntry{Kind: KindError, Content: "没有找到中文字体，选择窗口使用内置字体"}
+	})
+	return picker_face
+}
+
+// 打开选择窗口，同时只能打开一个
+func showPicker(p *Picker) error {
+	if !picker_opened.CompareAndSwap(false, true) {
+		return errors.New("选择窗口已经打开")
+	}
+
+	w, err := pickerScreen().NewWindow(&screen.NewWindowOptions{Width: 760, Height: 480, Title: "Clip 历史记录"})
+	if err != nil {
+		picker_opened.Store(false)
+		return err
+	}
+	go runPickerWindow(w, p)
+	return nil
+}
+
+type pickerWindow struct {
+	picker *Picker
+	face   font.Face
+	size   image.Point
+	buffer screen.Buffer
+	offset int
+
+	// 预览的图片，按条目缓存，避免每次绘制都解码
+	previewHash  string
+	previewImage image.Image
+}
+
+func runPickerWindow(w screen.Window, p *Picker) {
+	pw := &pickerWindow{picker: p, face: pickerFace()}
+	defer func() {
+		if pw.buffer != nil {
+			pw.buffer.Release()
+		}
+		w.Release()
+		picker_opened.Store(false)
+		global_log_channel <- LogEntry{Kind: KindInfo, Content: "关闭选择窗口"}
+	}()
+
+	for {
+		switch e := w.NextEvent().(type) {
+		case lifecycle.Event:
+			// 失去焦点或被关闭时关闭窗口
+			if e.To == lifecycle.StageDead || e.Crosses(lifecycle.StageFocused) == lifecycle.CrossOff {
+				return
+			}
+
+		case size.Event:
+			pw.size = e.Size()
+			if pw.buffer != nil {
+				pw.buffer.Release()
+				pw.buffer = nil
+			}
+			if pw.size.X > 0 && pw.size.Y > 0 {
+				buffer, err := pickerScreen().NewBuffer(pw.size)
+				if err != nil {
+					global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("创建选择窗口缓冲区失败: %v", err)}
+					return
+				}
+				pw.buffer = buffer
+			}
+
+		case paint.Event:
+			if pw.buffer == nil {
+				continue
+			}
+			pw.draw(pw.buffer.RGBA())
+			w.Upload(image.Point{}, pw.buffer, pw.buffer.Bounds())
+			w.Publish()
+
+		case key.Event:
+			if e.Direction == key.DirRelease {
+				continue
+			}
+			if !pw.handleKey(e) {
+				return
+			}
+			w.Send(paint.Event{})
+
+		case mouse.Event:
+			if !pw.handleMouse(e) {
+				return
+			}
+			w.Send(paint.Event{})
+
+		case error:
+			global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("选择窗口错误: %v", e)}
+		}
+	}
+}
+
+// 返回false时关闭窗口
+func (pw *pickerWindow) handleKey(e key.Event) bool {
+	p := pw.picker
+	control := e.Modifiers&(key.ModControl|key.ModMeta) != 0
+
+	switch {
+	case e.Code == key.CodeEscape:
+		if p.Query() == "" {
+			return false
+		}
+		p.SetQuery("")
+	case e.Code == key.CodeReturnEnter || e.Code == key.CodeKeypadEnter:
+		if p.Confirm() {
+			return false
+		}
+	case e.Code == key.CodeUpArrow:
+		p.Move(-1)
+	case e.Code == key.CodeDownArrow:
+		p.Move(1)
+	case e.Code == key.CodePageUp:
+		p.Move(-pw.rows())
+	case e.Code == key.CodePageDown:
+		p.Move(pw.rows())
+	case e.Code == key.CodeHome:
+		p.Select(0)
+	case e.Code == key.CodeEnd:
+		p.Select(len(p.Results()) - 1)
+	case e.Code == key.CodeTab:
+		p.SwitchTab(Ifel(e.Modifiers&key.ModShift != 0, -1, 1))
+	case e.Code == key.CodeDeleteBackspace:
+		p.Backspace()
+	case control && e.Code == key.CodeU:
+		p.SetQuery("")
+	case !control && e.Modifiers&key.ModAlt == 0 && e.Rune > 0 && unicode.IsPrint(e.Rune):
+		p.Type(e.Rune)
+	}
+	pw.scrollToSelected()
+	return true
+}
+
+// 单击选中，再次单击已选中的条目复制，滚轮滚动列表
+func (pw *pickerWindow) handleMouse(e mouse.Event) bool {
+	p := pw.picker
+	if e.Button.IsWheel() {
+		if e.Direction == mouse.DirStep || e.Direction == mouse.DirPress {
+			pw.offset += Ifel(e.Button == mouse.ButtonWheelUp, -3, 3)
+			pw.offset = max(0, min(pw.offset, len(p.Results())-pw.rows()))
+		}
+		return true
+	}
+	if e.Button != mouse.ButtonLeft || e.Direction != mouse.DirPress {
+		return true
+	}
+
+	x, y := int(e.X), int(e.Y)
+	lineHeight := pw.lineHeight()
+	if y < lineHeight {
+		// 点击标签页
+		tx := 0
+		for i, tab := range p.Tabs() {
+			width := font.MeasureString(pw.face, tab.Name).Ceil() + 16
+			if x >= tx && x < tx+width {
+				p.SwitchTab(i - p.Tab())
+				pw.offset = 0
+				return true
+			}
+			tx += width
+		}
+		return true
+	}
+
+	top := lineHeight * 2
+	if x < pw.listWidth() && y >= top {
+		index := pw.offset + (y-top)/lineHeight
+		if index < len(p.Results()) {
+			if index == p.SelectedIndex() {
+				return !p.Confirm()
+			}
+			p.Select(index)
+		}
+	}
+	return true
+}
+
+func (pw *pickerWindow) lineHeight() int {
+	return pw.face.Metrics().Height.Ceil() + 8
+}
+
+func (pw *pickerWindow) listWidth() int {
+	return pw.size.X * 9 / 20
+}
+
+// 列表可以显示的行数
+func (pw *pickerWindow) rows() int {
+	return max(1, (pw.size.Y-pw.lineHeight()*3)/pw.lineHeight())
+}
+
+func (pw *pickerWindow) scrollToSelected() {
+	selected := pw.picker.SelectedIndex()
+	if selected < pw.offset {
+		pw.offset = selected
+	} else if selected >= pw.offset+pw.rows() {
+		pw.offset = selected - pw.rows() + 1
+	}
+	pw.offset = max(0, pw.offset)
+}
+
+func (pw *pickerWindow) drawText(dst draw.Image, x, y int, text string, c color.Color) int {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: pw.face,
+		Dot:  fixed.P(x, y+(pw.lineHeight()+pw.face.Metrics().Ascent.Ceil()-pw.face.Metrics().Descent.Ceil())/2),
+	}
+	d.DrawString(text)
+	return d.Dot.X.Ceil()
+}
+
+// 不超过指定宽度的最长前缀的字节数，逐个字符累加宽度，超出宽度后不再测量剩余的文字
+func (pw *pickerWindow) fitWidth(text string, limit fixed.Int26_6) int {
+	var advance fixed.Int26_6
+	prev := rune(-1)
+	for i, r := range text {
+		if prev >= 0 {
+			advance += pw.face.Kern(prev, r)
+		}
+		glyph, _ := pw.face.GlyphAdvance(r)
+		advance += glyph
+		if advance > limit {
+			return i
+		}
+		prev = r
+	}
+	return len(text)
+}
+
+// 截断文字使其不超过指定宽度
+func (pw *pickerWindow) fitText(text string, width int) string {
+	if pw.fitWidth(text, fixed.I(width)) == len(text) {
+		return text
+	}
+	ellipsis := font.MeasureString(pw.face, "...")
+	return text[:pw.fitWidth(text, fixed.I(width)-ellipsis)] + "..."
+}
+
+// 按宽度折行，最多返回 maxLines 行，超出的文字不再测量
+func (pw *pickerWindow) wrapText(text string, width int, maxLines int) []string {
+	lines := []string{}
+	for rest, more := text, true; more && len(lines) < maxLines; {
+		var line string
+		line, rest, more = strings.Cut(rest, "\n")
+		line = strings.ReplaceAll(strings.TrimRight(line, "\r"), "\t", "    ")
+		if line == "" {
+			lines = append(lines, "")
+		}
+		for line != "" && len(lines) < maxLines {
+			n := pw.fitWidth(line, fixed.I(width))
+			// 每行至少一个字符
+			if n == 0 {
+				_, n = utf8.DecodeRuneInString(line)
+			}
+			lines = append(lines, line[:n])
+			line = line[n:]
+		}
+	}
+	return lines
+}
+
+func fillRect(dst draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(dst, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func (pw *pickerWindow) draw(dst *image.RGBA) {
+	p := pw.picker
+	bounds := dst.Bounds()
+	lineHeight := pw.lineHeight()
+	fillRect(dst, bounds, picker_color_background)
+
+	// 标签页
+	fillRect(dst, image.Rect(0, 0, bounds.Dx(), lineHeight), picker_color_bar)
+	x := 0
+	for i, tab := range p.Tabs() {
+		width := font.MeasureString(pw.face, tab.Name).Ceil() + 16
+		if i == p.Tab() {
+			fillRect(dst, image.Rect(x, 0, x+width, lineHeight), picker_color_selected)
+		}
+		pw.drawText(dst, x+8, 0, tab.Name, picker_color_text)
+		x += width
+	}
+
+	// 搜索框
+	x = pw.drawText(dst, 8, lineHeight, "搜索: ", picker_color_hint)
+	x = pw.drawText(dst, x, lineHeight, p.Query(), picker_color_text)
+	fillRect(dst, image.Rect(x+1, lineHeight+4, x+2, lineHeight*2-4), picker_color_text)
+	if err := p.QueryError(); err != nil {
+		pw.drawText(dst, x+12, lineHeight, pw.fitText(err.Error(), bounds.Dx()-x-20), picker_color_error)
+	}
+	fillRect(dst, image.Rect(0, lineHeight*2-1, bounds.Dx(), lineHeight*2), picker_color_border)
+
+	// 条目列表
+	top := lineHeight * 2
+	listWidth := pw.listWidth()
+	results := p.Results()
+	for i := 0; i < pw.rows() && pw.offset+i < len(results); i++ {
+		index := pw.offset + i
+		y := top + i*lineHeight
+		if index == p.SelectedIndex() {
+			fillRect(dst, image.Rect(0, y, listWidth, y+lineHeight), picker_color_selected)
+		}
+		pw.drawText(dst, 8, y, pw.fitText(pickerLabel(results[index]), listWidth-16), picker_color_text)
+	}
+	if len(results) == 0 {
+		pw.drawText(dst, 8, top, "没有条目", picker_color_hint)
+	}
+	fillRect(dst, image.Rect(listWidth, top, listWidth+1, bounds.Dy()-lineHeight), picker_color_border)
+
+	// 预览
+	if item := p.Selected(); item != nil {
+		pw.drawPreview(dst, image.Rect(listWidth+12, top+4, bounds.Dx()-12, bounds.Dy()-lineHeight-4), item)
+	}
+
+	// 提示
+	footer := bounds.Dy() - lineHeight
+	fillRect(dst, image.Rect(0, footer, bounds.Dx(), bounds.Dy()), picker_color_bar)
+	hint := fmt.Sprintf("↑↓ 选择  Enter 复制  Tab 切换分组  Esc 关闭    共 %d 条", len(results))
+	pw.drawText(dst, 8, footer, pw.fitText(hint, bounds.Dx()-16), picker_color_hint)
+}
+
+func (pw *pickerWindow) drawPreview(dst *image.RGBA, r image.Rectangle, item *ClipItem) {
+	lineHeight := pw.lineHeight()
+	info := fmt.Sprintf("%s  %s  %s", picker_type_names[item.Type], item.Time.Format("2006-01-02 15:04:05"), formatSize(len(item.Content)))
+	if item.UseCount > 0 {
+		info += fmt.Sprintf("  使用%d次", item.UseCount)
+	}
+	pw.drawText(dst, r.Min.X, r.Max.Y-lineHeight, pw.fitText(info, r.Dx()), picker_color_hint)
+	r.Max.Y -= lineHeight + 4
+
+	var text string
+	switch item.Type {
+	case TypeImage:
+		if pw.previewHash != item.Hash {
+			pw.previewHash = item.Hash
+			pw.previewImage, _, _ = image.Decode(bytes.NewReader(item.Content))
+		}
+		if pw.previewImage == nil {
+			text = "无法预览图片"
+			break
+		}
+		// 按比例缩小到预览区域内，不放大
+		src := pw.previewImage.Bounds()
+		scale := min(1, float64(r.Dx())/float64(src.Dx()), float64(r.Dy())/float64(src.Dy()))
+		target := image.Rect(0, 0, int(float64(src.Dx())*scale), int(float64(src.Dy())*scale)).Add(r.Min)
+		xdraw.ApproxBiLinear.Scale(dst, target, pw.previewImage, src, xdraw.Over, nil)
+		return
+
+	case TypeText:
+		text = string(item.Content)
+		if item.Sensitive {
+			text = maskSensitive(text) + "\n\n敏感内容已隐藏"
+		}
+
+	case TypeFiles:
+		text = string(item.Content)
+	}
+
+	for i, line := range pw.wrapText(text, r.Dx(), r.Dy()/lineHeight) {
+		pw.drawText(dst, r.Min.X, r.Min.Y+i*lineHeight, line, picker_color_text)
+	}
+}
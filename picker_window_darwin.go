@@ -0,0 +1,8 @@
+package main
+
+import "errors"
+
+// macOS 的显示服务需要运行在主线程，主线程已被托盘占用
+func showPicker(p *Picker) error {
+	return errors.New("macOS 暂不支持选择窗口")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// 保留策略，各项为 0 时不限制
+type RetentionPolicy struct {
+	MaxAge       uint `json:"max_age"`        // 最长保留天数
+	MaxBytes     uint `json:"max_bytes"`      // 最大占用空间，单位MB
+	MaxImages    uint `json:"max_images"`     // 最多图片条数
+	MaxRemote    uint `json:"max_remote"`     // 最多远程条目条数
+	RemoteMaxAge uint `json:"remote_max_age"` // 远程条目最长保留天数
+}
+
+// 统计已保留的条目，判断下一条是否超出限制
+type retentionCounter struct {
+	policy RetentionPolicy
+	now    time.Time
+	bytes  uint
+	images uint
+	remote uint
+}
+
+func newRetentionCounter(policy RetentionPolicy) *retentionCounter {
+	return &retentionCounter{policy: policy, now: time.Now()}
+}
+
+func expired(t time.Time, now time.Time, days uint) bool {
+	return days > 0 && now.Sub(t) > time.Duration(days)*24*time.Hour
+}
+
+// 条目按从新到旧的顺序传入，返回是否保留，保留时计入统计
+func (c *retentionCounter) keep(item *ClipItem, first bool) bool {
+	p := c.policy
+	// 最新的一条是当前剪贴板内容，不受保留时间限制
+	if !first && expired(item.Time, c.now, p.MaxAge) {
+		return false
+	}
+	if item.From == FromRemote {
+		if (!first && expired(item.Time, c.now, p.RemoteMaxAge)) || (p.MaxRemote > 0 && c.remote >= p.MaxRemote) {
+			return false
+		}
+	}
+	if item.Type == TypeImage && p.MaxImages > 0 && c.images >= p.MaxImages {
+		return false
+	}
+	// 最新的一条不受空间限制
+	size := uint(len(item.Content))
+	if !first && p.MaxBytes > 0 && c.bytes+size > p.MaxBytes*1024*1024 {
+		return false
+	}
+
+	c.bytes += size
+	if item.Type == TypeImage {
+		c.images++
+	}
+	if item.From == FromRemote {
+		c.remote++
+	}
+	return true
+}
+
+func formatDays(days uint) string {
+	return Ifel(days == 0, "不限制", fmt.Sprintf("%d天", days))
+}
+
+// 菜单中可选的值，0 为不限制
+var (
+	retention_age_options    = []uint{0, 1, 7, 30, 90}
+	retention_bytes_options  = []uint{0, 10, 50, 200}
+	retention_images_options = []uint{0, 10, 50, 100}
+	retention_remote_options = []uint{0, 10, 50}
+)
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 条目用于搜索的原始文本，图片使用识别出的文字
+func searchableText(item *ClipItem) string {
+	if item.Type == TypeImage {
+		return ocrText(item)
+	}
+	return string(item.Content)
+}
+
+type searchTerm struct {
+	text  string
+	exact bool
+	regex bool
+}
+
+type searchFilter struct {
+	negate bool
+	match  func(item *ClipItem, group string) bool
+}
+
+// 搜索语句：
+//   - 多个关键词用空格分隔，需要全部匹配，不区分大小写
+//   - 关键词不连续出现时按模糊（子序列）匹配，得分较低
+//   - "带空格的短语" 使用双引号，只做精确匹配
+//   - re:表达式 或 /表达式/ 使用正则匹配，默认不区分大小写，re: 之后直到末尾都是表达式，可以放在过滤条件和关键词之后
+//   - 过滤条件可以和关键词组合，前面加 - 表示排除：
+//     type:text|image|files  kind:url|email|json|...  from:local|remote  peer:地址  group:分组名
+//     after:2006-01-02  before:2006-01-02  date:today|yesterday  since:7d
+//     size>10k  size<1m
+type SearchQuery struct {
+	Text    string
+	terms   []searchTerm
+	regex   *regexp.Regexp
+	filters []searchFilter
+
+	// 判断条目是否属于某个分组，用于在历史记录中按分组过滤
+	InGroup func(group string, item *ClipItem) bool
+}
+
+type SearchResult struct {
+	Item  *ClipItem
+	Group string
+	Score float64
+}
+
+func ParseSearchQuery(text string) (*SearchQuery, error) {
+	q := &SearchQuery{Text: text}
+	text = strings.TrimSpace(text)
+
+	if len(text) > 2 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/") {
+		reg, err := compileSearchRegex(text[1 : len(text)-1])
+		if err != nil {
+			return nil, err
+		}
+		q.regex = reg
+		return q, nil
+	}
+
+	for _, term := range splitSearchTerms(text) {
+		if term.exact {
+			q.terms = append(q.terms, term)
+			continue
+		}
+
+		if term.regex {
+			reg, err := compileSearchRegex(term.text)
+			if err != nil {
+				return nil, err
+			}
+			q.regex = reg
+			continue
+		}
+
+		filter, ok, err := q.parseFilter(term.text)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			q.filters = append(q.filters, filter)
+		} else {
+			q.terms = append(q.terms, term)
+		}
+	}
+	return q, nil
+}
+
+func compileSearchRegex(pattern string) (*regexp.Regexp, error) {
+	if !strings.HasPrefix(pattern, "(?") {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+var searchFilterPattern = regexp.MustCompile(`^(-?)(type|kind|from|peer|group|after|before|date|since|size)(:|>|<)(.+)$`)
+
+func (q *SearchQuery) parseFilter(text string) (searchFilter, bool, error) {
+	groups := searchFilterPattern.FindStringSubmatch(text)
+	if groups == nil {
+		return searchFilter{}, false, nil
+	}
+
+	filter := searchFilter{negate: groups[1] == "-"}
+	key, op, value := groups[2], groups[3], groups[4]
+	if (key == "size") != (op != ":") {
+		return filter, false, fmt.Errorf("无效的过滤条件: %s", text)
+	}
+
+	switch key {
+	case "type":
+		types := map[ItemType]bool{}
+		for _, name := range strings.Split(value, "|") {
+			t, ok := map[string]ItemType{"text": TypeText, "image": TypeImage, "files": TypeFiles}[name]
+			if !ok {
+				return filter, false, fmt.Errorf("未知的类型: %s", name)
+			}
+			types[t] = true
+		}
+		filter.match = func(item *ClipItem, group string) bool { return types[item.Type] }
+	case "kind":
+		kinds := map[ContentKind]bool{}
+		for _, name := range strings.Split(value, "|") {
+			if _, ok := getContentDetector(ContentKind(name)); !ok {
+				return filter, false, fmt.Errorf("未知的内容类型: %s", name)
+			}
+			kinds[ContentKind(name)] = true
+		}
+		filter.match = func(item *ClipItem, group string) bool { return kinds[item.Kind] }
+	case "from":
+		from, ok := map[string]ItemFrom{"local": FromLocal, "remote": FromRemote}[value]
+		if !ok {
+			return filter, false, fmt.Errorf("未知的来源: %s", value)
+		}
+		filter.match = func(item *ClipItem, group string) bool { return item.From == from }
+	case "peer":
+		filter.match = func(item *ClipItem, group string) bool {
+			return item.From == FromRemote && strings.Contains(strings.ToLower(item.Peer), value)
+		}
+	case "group":
+		filter.match = func(item *ClipItem, group string) bool {
+			if group != "" {
+				return strings.EqualFold(group, value)
+			}
+			// 历史记录中的条目，检查是否同时存在于分组中
+			return q.InGroup != nil && q.InGroup(value, item)
+		}
+	case "after", "before", "date":
+		start, end, err := parseSearchDate(value)
+		if err != nil {
+			return filter, false, err
+		}
+		filter.match = func(item *ClipItem, group string) bool {
+			switch key {
+			case "after":
+				return !item.Time.Before(start)
+			case "before":
+				return item.Time.Before(start)
+			default:
+				return !item.Time.Before(start) && item.Time.Before(end)
+			}
+		}
+	case "since":
+		d, err := parseSearchDuration(value)
+		if err != nil {
+			return filter, false, err
+		}
+		filter.match = func(item *ClipItem, group string) bool { return time.Since(item.Time) <= d }
+	case "size":
+		size, err := parseSearchSize(value)
+		if err != nil {
+			return filter, false, err
+		}
+		filter.match = func(item *ClipItem, group string) bool {
+			return Ifel(op == ">", int64(len(item.Content)) > size, int64(len(item.Content)) < size)
+		}
+	}
+	return filter, true, nil
+}
+
+// 解析日期，返回当天的开始和结束时间
+func parseSearchDate(value string) (time.Time, time.Time, error) {
+	value = strings.ToUpper(value)
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	switch value {
+	case "TODAY":
+		return today, today.AddDate(0, 0, 1), nil
+	case "YESTERDAY":
+		return today.AddDate(0, 0, -1), today, nil
+	}
+
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, t.AddDate(0, 0, 1), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("无法解析日期: %s", value)
+}
+
+// 支持 d 表示天，其余使用 time.ParseDuration 的格式
+func parseSearchDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("无法解析时长: %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("无法解析时长: %s", value)
+	}
+	return d, nil
+}
+
+func parseSearchSize(value string) (int64, error) {
+	unit := int64(1)
+	switch {
+	case strings.HasSuffix(value, "k"):
+		unit, value = 1024, strings.TrimSuffix(value, "k")
+	case strings.HasSuffix(value, "m"):
+		unit, value = 1024*1024, strings.TrimSuffix(value, "m")
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("无法解析大小: %s", value)
+	}
+	return n * unit, nil
+}
+
+func splitSearchTerms(text string) []searchTerm {
+	terms := []searchTerm{}
+	var current strings.Builder
+	quoted := false
+	flush := func(exact bool) {
+		if current.Len() > 0 {
+			terms = append(terms, searchTerm{text: strings.ToLower(current.String()), exact: exact})
+			current.Reset()
+		}
+	}
+
+	for i, r := range text {
+		// re: 之后的内容保留大小写和空格，作为一个正则表达式
+		if !quoted && current.Len() == 0 && strings.HasPrefix(text[i:], "re:") {
+			if pattern := strings.TrimSpace(text[i+len("re:"):]); pattern != "" {
+				terms = append(terms, searchTerm{text: pattern, regex: true})
+			}
+			return terms
+		}
+
+		switch {
+		case r == '"':
+			flush(quoted)
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			flush(false)
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush(quoted)
+	return terms
+}
+
+func (q *SearchQuery) Empty() bool {
+	return q.regex == nil && len(q.terms) == 0 && len(q.filters) == 0
+}
+
+// 返回匹配质量得分，不匹配时ok为false，group为条目所在的分组，历史记录为空
+func (q *SearchQuery) Match(item *ClipItem, group string) (score float64, ok bool) {
+	for _, filter := range q.filters {
+		if filter.match(item, group) == filter.negate {
+			return 0, false
+		}
+	}
+
+	if q.regex == nil && len(q.terms) == 0 {
+		return 0, true
+	}
+
+	// 正则匹配原始文本，大小写由表达式决定，得分按一个关键词计算
+	count := len(q.terms)
+	if q.regex != nil {
+		loc := q.regex.FindStringIndex(searchableText(item))
+		if loc == nil {
+			return 0, false
+		}
+		score += 100 + Ifel(loc[0] == 0, 30.0, 0.0)
+		count++
+	}
+
+	if len(q.terms) > 0 {
+		text := global_search_index.Lower(item)
+		if text == "" {
+			return 0, false
+		}
+		for _, term := range q.terms {
+			s, ok := matchSearchTerm(text, term)
+			if !ok {
+				return 0, false
+			}
+			score += s
+		}
+	}
+	return score / float64(count), true
+}
+
+func matchSearchTerm(text string, term searchTerm) (float64, bool) {
+	if i := strings.Index(text, term.text); i >= 0 {
+		score := 100.0
+		if i == 0 {
+			score += 30
+		} else if r, _ := utf8.DecodeLastRuneInString(text[:i]); !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			// 单词开头
+			score += 20
+		}
+		if len(text) == len(term.text) {
+			score += 20
+		}
+		return score, true
+	}
+
+	if term.exact {
+		return 0, false
+	}
+
+	// 模糊匹配：关键词中的字符按顺序出现，越紧凑得分越高
+	pattern := []rune(term.text)
+	if len(pattern) < 2 {
+		return 0, false
+	}
+	start, matched := -1, 0
+	for i, r := range []rune(text) {
+		if r != pattern[matched] {
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		matched++
+		if matched == len(pattern) {
+			return 50 * float64(len(pattern)) / float64(i-start+1), true
+		}
+	}
+	return 0, false
+}
+
+// 越新的条目加分越多，一天前的条目加分减半
+func recencyScore(t time.Time) float64 {
+	hours := math.Max(time.Since(t).Hours(), 0)
+	return 50 / (1 + hours/24)
+}
+
+// 在条目中搜索，按匹配质量和时间排序，group为条目所在的分组，历史记录为空
+func SearchItems(q *SearchQuery, items []*ClipItem, group string) []*ClipItem {
+	results := searchItems(q, items, group, global_search_index.Candidates(q))
+	sorted := make([]*ClipItem, len(results))
+	for i, result := range results {
+		sorted[i] = result.Item
+	}
+	return sorted
+}
+
+// 在历史记录和所有分组中搜索
+func SearchAll(q *SearchQuery, history []*ClipItem, groups map[string][]*ClipItem) []SearchResult {
+	candidates := global_search_index.Candidates(q)
+	results := searchItems(q, history, "", candidates)
+	for name, items := range groups {
+		results = append(results, searchItems(q, items, name, candidates)...)
+	}
+	sortSearchResults(results)
+	return results
+}
+
+// candidates 为索引筛选出的内容，为 nil 时逐条匹配，不在索引中的条目同样逐条匹配
+func searchItems(q *SearchQuery, items []*ClipItem, group string, candidates map[string]bool) []SearchResult {
+	results := []SearchResult{}
+	for _, item := range items {
+		if candidates != nil && !candidates[item.Hash] && global_search_index.Contains(item) {
+			continue
+		}
+		score, ok := q.Match(item, group)
+		if !ok {
+			continue
+		}
+		results = append(results, SearchResult{Item: item, Group: group, Score: score + recencyScore(item.Time)})
+	}
+	sortSearchResults(results)
+	return results
+}
+
+func sortSearchResults(results []SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+}
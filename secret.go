@@ -0,0 +1,201 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// 常见密钥、令牌的格式
+var secret_patterns = []struct {
+	Name    string
+	Pattern *regexp.Regexp
+}{
+	{"私钥", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{"AWS密钥", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub令牌", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})\b`)},
+	{"GitLab令牌", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`)},
+	{"Slack令牌", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{"Google API密钥", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"Stripe密钥", regexp.MustCompile(`\b[sr]k_(live|test)_[0-9A-Za-z]{16,}\b`)},
+	{"API密钥", regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}\b`)},
+	{"npm令牌", regexp.MustCompile(`\bnpm_[A-Za-z0-9]{36}\b`)},
+	{"Hugging Face令牌", regexp.MustCompile(`\bhf_[A-Za-z0-9]{30,}\b`)},
+	{"JWT", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{"密码", regexp.MustCompile(`(?i)\b(password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key)\s*[:=]\s*\S{6,}`)},
+}
+
+var credit_card_regexp = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+// 令牌只包含这些字符，带点、括号、引号等的文本是代码或路径
+var token_regexp = regexp.MustCompile(`^[A-Za-z0-9_\-+/=!@#$%^&*?~]+$`)
+
+// 标识符按单词拆分，例如 getUserById 拆成 get User By Id
+var word_regexp = regexp.MustCompile(`[A-Z]?[a-z]+|[A-Z]+|[0-9]+`)
+
+// 识别为其他类型的文本不会是密钥，也不检查银行卡号
+var non_secret_kinds = []ContentKind{ContentURL, ContentEmail, ContentPath, ContentUUID, ContentIP, ContentColor, ContentTimestamp}
+
+// 检测文本是否为敏感内容，返回敏感内容的类型
+func detectSensitive(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", false
+	}
+
+	for _, p := range secret_patterns {
+		if p.Pattern.MatchString(text) {
+			return p.Name, true
+		}
+	}
+
+	if slices.Contains(non_secret_kinds, detectContentKind(text)) {
+		return "", false
+	}
+
+	for _, match := range credit_card_regexp.FindAllString(text, -1) {
+		if luhnValid(match) {
+			return "银行卡号", true
+		}
+	}
+
+	if isHighEntropy(text) {
+		return "随机字符串", true
+	}
+	return "", false
+}
+
+// Luhn 校验，过滤随机的长数字
+func luhnValid(number string) bool {
+	digits := []int{}
+	for _, r := range number {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// 单个较长的令牌，混合大小写、数字、符号且信息熵较高，通常是生成的密码或密钥
+// 标识符、带点的路径和代码不算，它们由完整的单词组成
+func isHighEntropy(text string) bool {
+	if len(text) < 20 || len(text) > 128 || !token_regexp.MatchString(text) {
+		return false
+	}
+
+	var upper, lower, digit, symbol int
+	counts := make(map[rune]int)
+	for _, r := range text {
+		counts[r]++
+		switch {
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	if upper+lower+digit+symbol < 3 {
+		return false
+	}
+
+	// 随机字符串拆出的单词很短，标识符的单词平均在4个字符以上
+	words := word_regexp.FindAllString(text, -1)
+	letters := 0
+	for _, word := range words {
+		letters += len(word)
+	}
+	if len(words) == 0 || letters/len(words) >= 4 {
+		return false
+	}
+
+	entropy := 0.0
+	length := float64(len(text))
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= 3.5
+}
+
+// 密码管理器复制时添加的隐藏标记
+var concealed_clipboard_types = []string{
+	"x-kde-passwordManagerHint",
+	"org.nspasteboard.ConcealedType",
+	"ExcludeClipboardContentFromMonitorProcessing",
+	"Clipboard Viewer Ignore",
+}
+
+// 剪贴板数据类型中是否有隐藏标记
+func hasConcealedType(types []string) bool {
+	for _, t := range types {
+		for _, concealed := range concealed_clipboard_types {
+			if strings.EqualFold(strings.TrimSpace(t), concealed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 检测敏感内容并标记，只检测文本
+func (c *ClipItem) DetectSensitive() {
+	if c.Type != TypeText {
+		return
+	}
+	if _, ok := detectSensitive(string(c.Content)); ok {
+		c.Sensitive = true
+	}
+}
+
+// 日志中显示的条目，未标记但内容像密钥的文本同样隐藏
+func formatLogItem(item *ClipItem) string {
+	if item.Type == TypeText && !item.Sensitive {
+		if _, ok := detectSensitive(string(item.Content)); ok {
+			masked := *item
+			masked.Sensitive = true
+			return formatMenuItem(&masked)
+		}
+	}
+	return formatMenuItem(item)
+}
+
+// 去除敏感内容，用于保存到本地
+func withoutSensitive(items []*ClipItem) []*ClipItem {
+	result := []*ClipItem{}
+	for _, item := range items {
+		if !item.Sensitive {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// 敏感内容的显示文本，只保留开头的两个字符
+func maskSensitive(text string) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= 4 {
+		return "••••••"
+	}
+	return string(runes[:2]) + "••••••"
+}
@@ -0,0 +1,30 @@
+package main
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework AppKit
+#import <AppKit/AppKit.h>
+#include <stdlib.h>
+
+// 返回剪贴板中的数据类型，每行一个，由调用方释放
+static char *clipboard_types(void) {
+	@autoreleasepool {
+		NSArray<NSPasteboardType> *types = [[NSPasteboard generalPasteboard] types];
+		NSString *joined = [types componentsJoinedByString:@"\n"];
+		return strdup(joined == nil ? "" : [joined UTF8String]);
+	}
+}
+*/
+import "C"
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// 剪贴板内容是否被密码管理器标记为隐藏，直接读取 NSPasteboard 的数据类型
+func clipboardConcealed() bool {
+	types := C.clipboard_types()
+	defer C.free(unsafe.Pointer(types))
+	return hasConcealedType(strings.Split(C.GoString(types), "\n"))
+}
@@ -0,0 +1,151 @@
+package main
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <string.h>
+#include <time.h>
+#include <sys/select.h>
+#include <X11/Xlib.h>
+#include <X11/Xatom.h>
+
+// 运行时加载 libX11，连接和接收数据的窗口保持打开，避免每次文本变化都重新连接
+static void* conceal_x11 = NULL;
+static Display* conceal_display = NULL;
+static Window conceal_window = None;
+static Display* (*pXOpenDisplay)(const char*);
+static Window (*pXDefaultRootWindow)(Display*);
+static Window (*pXCreateSimpleWindow)(Display*, Window, int, int, unsigned int, unsigned int, unsigned int, unsigned long, unsigned long);
+static Atom (*pXInternAtom)(Display*, const char*, Bool);
+static char* (*pXGetAtomName)(Display*, Atom);
+static Window (*pXGetSelectionOwner)(Display*, Atom);
+static int (*pXConvertSelection)(Display*, Atom, Atom, Atom, Window, Time);
+static Bool (*pXCheckTypedWindowEvent)(Display*, Window, int, XEvent*);
+static int (*pXGetWindowProperty)(Display*, Window, Atom, long, long, Bool, Atom, Atom*, int*, unsigned long*, unsigned long*, unsigned char**);
+static int (*pXConnectionNumber)(Display*);
+static int (*pXFlush)(Display*);
+static int (*pXFree)(void*);
+
+static int conceal_open() {
+	if (conceal_x11 == NULL) {
+		conceal_x11 = dlopen("libX11.so", RTLD_LAZY);
+		if (conceal_x11 == NULL) {
+			conceal_x11 = dlopen("libX11.so.6", RTLD_LAZY);
+		}
+		if (conceal_x11 == NULL) {
+			return 0;
+		}
+		pXOpenDisplay = dlsym(conceal_x11, "XOpenDisplay");
+		pXDefaultRootWindow = dlsym(conceal_x11, "XDefaultRootWindow");
+		pXCreateSimpleWindow = dlsym(conceal_x11, "XCreateSimpleWindow");
+		pXInternAtom = dlsym(conceal_x11, "XInternAtom");
+		pXGetAtomName = dlsym(conceal_x11, "XGetAtomName");
+		pXGetSelectionOwner = dlsym(conceal_x11, "XGetSelectionOwner");
+		pXConvertSelection = dlsym(conceal_x11, "XConvertSelection");
+		pXCheckTypedWindowEvent = dlsym(conceal_x11, "XCheckTypedWindowEvent");
+		pXGetWindowProperty = dlsym(conceal_x11, "XGetWindowProperty");
+		pXConnectionNumber = dlsym(conceal_x11, "XConnectionNumber");
+		pXFlush = dlsym(conceal_x11, "XFlush");
+		pXFree = dlsym(conceal_x11, "XFree");
+	}
+	if (conceal_display == NULL) {
+		conceal_display = pXOpenDisplay(NULL);
+		if (conceal_display == NULL) {
+			return 0;
+		}
+		conceal_window = pXCreateSimpleWindow(conceal_display, pXDefaultRootWindow(conceal_display), 0, 0, 1, 1, 0, 0, 0);
+	}
+	return 1;
+}
+
+static long conceal_elapsed_ms(struct timespec* start) {
+	struct timespec now;
+	clock_gettime(CLOCK_MONOTONIC, &now);
+	return (now.tv_sec - start->tv_sec) * 1000 + (now.tv_nsec - start->tv_nsec) / 1000000;
+}
+
+// 向剪贴板所有者请求 TARGETS，数据类型名称按行写入 buf，没有所有者或超时返回 0
+static int conceal_targets(char* buf, int size, int timeout_ms) {
+	Display* d = conceal_display;
+	Atom clipboard = pXInternAtom(d, "CLIPBOARD", False);
+	if (pXGetSelectionOwner(d, clipboard) == None) {
+		return 0;
+	}
+	Atom prop = pXInternAtom(d, "CLIP_TARGETS", False);
+
+	// 丢弃上次超时后才到达的回复
+	XEvent event;
+	while (pXCheckTypedWindowEvent(d, conceal_window, SelectionNotify, &event)) {
+	}
+	pXConvertSelection(d, clipboard, pXInternAtom(d, "TARGETS", False), prop, conceal_window, CurrentTime);
+	pXFlush(d);
+
+	struct timespec start;
+	clock_gettime(CLOCK_MONOTONIC, &start);
+	int fd = pXConnectionNumber(d);
+	while (!pXCheckTypedWindowEvent(d, conceal_window, SelectionNotify, &event)) {
+		long remaining = timeout_ms - conceal_elapsed_ms(&start);
+		if (remaining <= 0) {
+			return 0;
+		}
+		fd_set fds;
+		FD_ZERO(&fds);
+		FD_SET(fd, &fds);
+		struct timeval tv = {remaining / 1000, (remaining % 1000) * 1000};
+		select(fd + 1, &fds, NULL, NULL, &tv);
+	}
+	if (event.xselection.property == None) {
+		return 0;
+	}
+
+	Atom actual;
+	int format;
+	unsigned long count, after;
+	unsigned char* data = NULL;
+	if (pXGetWindowProperty(d, conceal_window, prop, 0, 1024, True, XA_ATOM, &actual, &format, &count, &after, &data) != Success || data == NULL) {
+		return 0;
+	}
+	Atom* atoms = (Atom*)data;
+	int used = 0;
+	for (unsigned long i = 0; i < count; i++) {
+		char* name = pXGetAtomName(d, atoms[i]);
+		if (name == NULL) {
+			continue;
+		}
+		int length = strlen(name);
+		if (used + length + 2 <= size) {
+			memcpy(buf + used, name, length);
+			used += length;
+			buf[used++] = '\n';
+		}
+		pXFree(name);
+	}
+	buf[used] = 0;
+	pXFree(data);
+	return 1;
+}
+*/
+import "C"
+
+import (
+	"strings"
+	"sync"
+)
+
+var conceal_mu sync.Mutex
+
+// 剪贴板内容是否被密码管理器标记为隐藏，通过 X11 向剪贴板所有者请求数据类型
+// 监听只在文本变化时检查，所有者 300 毫秒内没有回复视为没有标记，Wayland 原生应用需要通过 XWayland 同步剪贴板
+func clipboardConcealed() bool {
+	conceal_mu.Lock()
+	defer conceal_mu.Unlock()
+
+	if C.conceal_open() == 0 {
+		return false
+	}
+	var buf [4096]C.char
+	if C.conceal_targets(&buf[0], C.int(len(buf)), 300) == 0 {
+		return false
+	}
+	return hasConcealedType(strings.Split(C.GoString(&buf[0]), "\n"))
+}
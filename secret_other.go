@@ -0,0 +1,8 @@
+//go:build !linux && !windows && !darwin
+
+package main
+
+// 不支持的平台无法读取剪贴板数据类型
+func clipboardConcealed() bool {
+	return false
+}
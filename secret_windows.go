@@ -0,0 +1,37 @@
+package main
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procRegisterClipboardFormatW   = user32.NewProc("RegisterClipboardFormatW")
+	procIsClipboardFormatAvailable = user32.NewProc("IsClipboardFormatAvailable")
+
+	concealed_clipboard_formats      []uintptr
+	concealed_clipboard_formats_once sync.Once
+)
+
+// 剪贴板内容是否被密码管理器标记为隐藏，直接查询剪贴板格式，不需要打开剪贴板
+func clipboardConcealed() bool {
+	concealed_clipboard_formats_once.Do(func() {
+		for _, name := range concealed_clipboard_types {
+			ptr, err := syscall.UTF16PtrFromString(name)
+			if err != nil {
+				continue
+			}
+			if format, _, _ := procRegisterClipboardFormatW.Call(uintptr(unsafe.Pointer(ptr))); format != 0 {
+				concealed_clipboard_formats = append(concealed_clipboard_formats, format)
+			}
+		}
+	})
+
+	for _, format := range concealed_clipboard_formats {
+		if ok, _, _ := procIsClipboardFormatAvailable.Call(format); ok != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -1,18 +1,167 @@
 package main
 
 import (
+	"crypto/md5"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 )
 
+// 文件分块大小
+const const_share_chunk_size = 64 * 1024
+
+// 单条消息的写入超时，超时的连接会被关闭，避免一个客户端阻塞其他客户端
+const const_share_write_timeout = 10 * time.Second
+
+// 每个连接等待发送的消息数，超过时丢弃新的剪贴板内容
+const const_share_queue_size = 32
+
+// 最多保留的共享文件数，超过时删除最早共享的文件
+const const_share_max_files = 256
+
+type ShareMessageKind string
+
+const (
+	MessageItem        ShareMessageKind = "item"
+	MessageFileRequest ShareMessageKind = "file_request"
+	MessageFileChunk   ShareMessageKind = "file_chunk"
+)
+
+type ShareFile struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+type ShareMessage struct {
+	Kind   ShareMessageKind `json:"kind"`
+	Item   *ClipItem        `json:"item,omitempty"`
+	Files  []ShareFile      `json:"files,omitempty"`
+	FileID string           `json:"file_id,omitempty"`
+	Offset int64            `json:"offset,omitempty"`
+	Data   []byte           `json:"data,omitempty"`
+	EOF    bool             `json:"eof,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// 使用长度前缀协议：4字节长度 + JSON数据
+func writeShareMessage(w io.Writer, msg *ShareMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	copy(buf[4:], data)
+	_, err = w.Write(buf)
+	return err
+}
+
+func readShareMessage(r io.Reader) (*ShareMessage, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	var msg ShareMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// 与客户端的连接，消息由单独的协程按顺序写入，写入慢的客户端不会阻塞共享和其他客户端
+// 剪贴板内容和文件分块使用不同的队列，发送文件时剪贴板内容优先写入
+type shareConn struct {
+	conn   net.Conn
+	items  chan *ShareMessage
+	chunks chan *ShareMessage
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newShareConn(conn net.Conn) *shareConn {
+	c := &shareConn{
+		conn:   conn,
+		items:  make(chan *ShareMessage, const_share_queue_size),
+		chunks: make(chan *ShareMessage),
+		done:   make(chan struct{}),
+	}
+	go c.writeLoop()
+	return c
+}
+
+func (c *shareConn) writeLoop() {
+	for {
+		var msg *ShareMessage
+		select {
+		case msg = <-c.items:
+		default:
+			select {
+			case <-c.done:
+				return
+			case msg = <-c.items:
+			case msg = <-c.chunks:
+			}
+		}
+
+		c.conn.SetWriteDeadline(time.Now().Add(const_share_write_timeout))
+		if err := writeShareMessage(c.conn, msg); err != nil {
+			global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("发送到%s失败，断开连接: %v", c.conn.RemoteAddr(), err)}
+			c.Close()
+			return
+		}
+	}
+}
+
+// 加入剪贴板内容的发送队列，队列已满时丢弃，返回是否加入
+func (c *shareConn) trySend(msg *ShareMessage) bool {
+	select {
+	case c.items <- msg:
+		return true
+	case <-c.done:
+		return false
+	default:
+		return false
+	}
+}
+
+// 等待发送文件分块，连接关闭时返回 false
+func (c *shareConn) send(msg *ShareMessage) bool {
+	select {
+	case c.chunks <- msg:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+func (c *shareConn) Close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
 type ShareServer struct{
 	ln net.Listener
 	addrString string
-	conns map[net.Conn]bool
+	conns map[*shareConn]bool
+	files map[string]string
+	fileOrder []string // 按共享顺序的文件ID，用于删除最早的文件
 	mu sync.Mutex
 }
 
@@ -37,7 +186,8 @@ func NewShareServer() * ShareServer{
 	server := &ShareServer{
 		ln: ln,
 		addrString: addrString,
-		conns: make(map[net.Conn]bool),
+		conns: make(map[*shareConn]bool),
+		files: make(map[string]string),
 	}
 	return server
 }
@@ -52,11 +202,12 @@ func (s *ShareServer) Start() {
 			}
 
 			global_log_channel <- LogEntry{Kind: KindInfo, Content: "一个客户端已连接"}
+			c := newShareConn(conn)
 			s.mu.Lock()
-			s.conns[conn] = true
+			s.conns[c] = true
 			s.mu.Unlock()
 
-			go func (c net.Conn)  {
+			go func (c *shareConn)  {
 			    defer func() {
 					c.Close()
 					s.mu.Lock()
@@ -64,15 +215,17 @@ func (s *ShareServer) Start() {
 					s.mu.Unlock()
 				}()
 				
-				// 保持连接，不读取数据
-				buf := make([]byte, 1)
+				// 保持连接，处理客户端的文件请求，文件在单独的协程中发送，不影响读取后续请求
 				for {
-					_, err := c.Read(buf)
+					msg, err := readShareMessage(c.conn)
 					if err != nil {
 						break
 					}
+					if msg.Kind == MessageFileRequest {
+						go s.sendFile(c, msg.FileID, msg.Offset)
+					}
 				}
-			}(conn)
+			}(c)
 		}
 	}()
 }
@@ -82,8 +235,8 @@ func (s *ShareServer) Stop(){
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for conn := range s.conns {
-		conn.Close()
+	for c := range s.conns {
+		c.Close()
 	}
 	s.ln.Close()
 }
@@ -92,32 +245,126 @@ func (s *ShareServer) AddrString() string {
 	return s.addrString
 }
 
+// 发送给所有客户端，只在锁内复制连接列表，写入由各连接的协程完成
+func (s *ShareServer) broadcast(msg *ShareMessage) {
+	s.mu.Lock()
+	conns := make([]*shareConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		if !c.trySend(msg) {
+			global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("%s的发送队列已满，丢弃剪贴板内容", c.conn.RemoteAddr())}
+		}
+	}
+}
+
 func (s *ShareServer) Share(item *ClipItem) {
-	global_log_channel <- LogEntry{Kind: KindInfo, Content: "准备发送剪贴板内容。"}
-	data, err := json.Marshal(item)
-	if(err != nil){
+	global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("发送剪贴板内容，长度为%d字节", len(item.Content))}
+	s.broadcast(&ShareMessage{Kind: MessageItem, Item: item})
+}
+
+// 发送文件列表，只发送文件信息，文件内容由客户端按需请求
+// 文件列表不会自动共享，只在用户选择发送时调用，避免复制的路径把本机文件发送出去
+func (s *ShareServer) ShareFiles(item *ClipItem) {
+	msg := &ShareMessage{Kind: MessageItem, Item: item}
+	for _, path := range strings.Split(string(item.Content), "\n") {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("共享文件失败: 无法读取文件%s", path)}
+			continue
+		}
+		id := fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%s|%d|%d", path, info.Size(), info.ModTime().UnixNano()))))
+		s.addFile(id, path)
+		msg.Files = append(msg.Files, ShareFile{ID: id, Name: info.Name(), Size: info.Size()})
+	}
+	if len(msg.Files) == 0 {
 		return
 	}
 
+	global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("发送文件列表，共%d个文件", len(msg.Files))}
+	s.broadcast(msg)
+}
+
+// 记录共享的文件，超过数量上限时删除最早共享的文件
+func (s *ShareServer) addFile(id string, path string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	
-	// 使用长度前缀协议：4字节长度 + JSON数据
-	length := uint32(len(data))
-	header := make([]byte, 4)
-	binary.BigEndian.PutUint32(header, length)
-	
-	global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("发送剪贴板内容，长度为%d字节", length)}
-	for conn := range s.conns{
-		conn.Write(header)
-		conn.Write(data)
+
+	if _, ok := s.files[id]; ok {
+		return
+	}
+	s.files[id] = path
+	s.fileOrder = append(s.fileOrder, id)
+	if len(s.fileOrder) > const_share_max_files {
+		delete(s.files, s.fileOrder[0])
+		s.fileOrder = s.fileOrder[1:]
 	}
 }
 
+// 从offset开始分块发送文件，用于断点续传
+func (s *ShareServer) sendFile(c *shareConn, id string, offset int64) {
+	s.mu.Lock()
+	path, ok := s.files[id]
+	s.mu.Unlock()
+
+	if !ok {
+		c.send(&ShareMessage{Kind: MessageFileChunk, FileID: id, Offset: offset, EOF: true, Error: "文件未共享"})
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		c.send(&ShareMessage{Kind: MessageFileChunk, FileID: id, Offset: offset, EOF: true, Error: err.Error()})
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		c.send(&ShareMessage{Kind: MessageFileChunk, FileID: id, Offset: offset, EOF: true, Error: err.Error()})
+		return
+	}
+
+	global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("开始发送文件%s，起始位置%d", path, offset)}
+	for {
+		// 每个分块使用新的缓冲区，交给写入协程后可能还未写入
+		buf := make([]byte, const_share_chunk_size)
+		n, err := file.Read(buf)
+		eof := err == io.EOF
+		if err != nil && !eof {
+			c.send(&ShareMessage{Kind: MessageFileChunk, FileID: id, Offset: offset, EOF: true, Error: err.Error()})
+			return
+		}
+
+		if !c.send(&ShareMessage{Kind: MessageFileChunk, FileID: id, Offset: offset, Data: buf[:n], EOF: eof}) {
+			return
+		}
+		offset += int64(n)
+		if eof {
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("文件%s发送完成", path)}
+			return
+		}
+	}
+}
+
+
+// 一次文件列表的下载任务
+type shareDownload struct {
+	files    []ShareFile
+	index    int
+	file     *os.File
+	received int64
+	progress int64
+	paths    []string
+}
 
 type ShareClient struct{
 	addr string
 	conn net.Conn
+	download *shareDownload
+	queue []*shareDownload
 	onShare func(item *ClipItem)
 	onClose func()
 }
@@ -145,34 +392,229 @@ func (c *ShareClient) ConnectTo() bool{
 			}
 		}()
 
-		// 使用长度前缀协议读取
-		header := make([]byte, 4)
+		// 断开时保存未完成的下载，重新连接到同一地址后继续
+		defer c.savePending()
+
+		if c.restorePending() {
+			c.nextDownload(conn)
+		}
+
 		for {
-			// 读取4字节长度头
-			if _, err := io.ReadFull(conn, header); err != nil {
-				break
-			}
-			global_log_channel <- LogEntry{Kind: KindInfo, Content: "收到剪贴板内容，正在读取..."}
-			
-			length := binary.BigEndian.Uint32(header)
-			
-			// 读取完整的JSON数据
-			data := make([]byte, length)
-			if _, err := io.ReadFull(conn, data); err != nil {
+			msg, err := readShareMessage(conn)
+			if err != nil {
 				break
 			}
-			
-			var item ClipItem
-			if err := json.Unmarshal(data, &item); err == nil {
+
+			switch msg.Kind {
+			case MessageItem:
+				global_log_channel <- LogEntry{Kind: KindInfo, Content: "收到剪贴板内容，正在读取..."}
+				if msg.Item == nil {
+					continue
+				}
+				if msg.Item.Type == TypeFiles && len(msg.Files) > 0 {
+					// 文件ID由对方发送，只接受32位十六进制，避免写入下载目录以外的位置
+					files := []ShareFile{}
+					for _, f := range msg.Files {
+						if !validHash(f.ID) {
+							global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("忽略文件%s: 无效的文件ID", f.Name)}
+							continue
+						}
+						files = append(files, f)
+					}
+					if len(files) == 0 {
+						continue
+					}
+					c.queue = append(c.queue, &shareDownload{files: files})
+					if c.download == nil {
+						c.nextDownload(conn)
+					}
+					continue
+				}
 				if c.onShare != nil{
-					c.onShare(item.CloneToRemote())
+					item := msg.Item.CloneToRemote()
+					item.Peer = c.addr
+					c.onShare(item)
 				}
+			case MessageFileChunk:
+				c.receiveChunk(conn, msg)
 			}
 		}
 	}(c.conn)
 	return true;
 }
 
+// 未完成的下载，按服务器地址保存，重新连接时继续
+var (
+	global_share_pending    = make(map[string][]*shareDownload)
+	global_share_pending_mu sync.Mutex
+)
+
+// 关闭正在写入的临时文件，将当前和排队的下载保存到未完成列表，已写入的部分用于续传
+func (c *ShareClient) savePending() {
+	queue := c.queue
+	if d := c.download; d != nil {
+		if d.file != nil {
+			d.file.Close()
+			d.file = nil
+		}
+		queue = append([]*shareDownload{d}, queue...)
+	}
+	c.download, c.queue = nil, nil
+	if len(queue) == 0 {
+		return
+	}
+
+	global_share_pending_mu.Lock()
+	defer global_share_pending_mu.Unlock()
+	global_share_pending[c.addr] = append(global_share_pending[c.addr], queue...)
+	global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("与%s的连接已断开，%d个文件列表未下载完成，重新连接后继续", c.addr, len(queue))}
+}
+
+// 取出该地址未完成的下载，返回是否有需要继续的下载
+func (c *ShareClient) restorePending() bool {
+	global_share_pending_mu.Lock()
+	queue := global_share_pending[c.addr]
+	delete(global_share_pending, c.addr)
+	global_share_pending_mu.Unlock()
+
+	if len(queue) == 0 {
+		return false
+	}
+	global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("继续从%s下载%d个文件列表", c.addr, len(queue))}
+	c.queue = append(queue, c.queue...)
+	return true
+}
+
+func (c *ShareClient) nextDownload(conn net.Conn) {
+	c.download = nil
+	if len(c.queue) == 0 {
+		return
+	}
+	c.download = c.queue[0]
+	c.queue = c.queue[1:]
+	c.requestFile(conn)
+}
+
+// 请求当前文件，已经存在的临时文件会从断点继续下载
+func (c *ShareClient) requestFile(conn net.Conn) {
+	d := c.download
+	if d.index >= len(d.files) {
+		global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("文件接收完成，共%d个", len(d.paths))}
+		if len(d.paths) > 0 && c.onShare != nil {
+			item := NewClipItemFromRemote(TypeFiles, []byte(strings.Join(d.paths, "\n")))
+			item.Peer = c.addr
+			c.onShare(item)
+		}
+		c.nextDownload(conn)
+		return
+	}
+
+	f := d.files[d.index]
+	dir := getDownloadDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("创建下载目录失败: %v", err)}
+		c.download = nil
+		return
+	}
+
+	name := f.ID + ".part"
+	if !validHash(f.ID) || !filepath.IsLocal(name) {
+		global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("忽略文件%s: 无效的文件ID", f.Name)}
+		d.index++
+		c.requestFile(conn)
+		return
+	}
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("创建临时文件失败: %v", err)}
+		d.index++
+		c.requestFile(conn)
+		return
+	}
+	info, _ := file.Stat()
+	d.file = file
+	d.received = info.Size()
+	d.progress = -1
+
+	global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("请求文件%s，从%d字节开始", f.Name, d.received)}
+	writeShareMessage(conn, &ShareMessage{Kind: MessageFileRequest, FileID: f.ID, Offset: d.received})
+}
+
+func (c *ShareClient) receiveChunk(conn net.Conn, msg *ShareMessage) {
+	d := c.download
+	if d == nil || d.index >= len(d.files) || d.files[d.index].ID != msg.FileID {
+		return
+	}
+	f := d.files[d.index]
+
+	if msg.Error != "" {
+		global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("接收文件%s失败: %s", f.Name, msg.Error)}
+		d.file.Close()
+		d.index++
+		c.requestFile(conn)
+		return
+	}
+
+	// 分块必须紧接已写入的部分，否则放弃该文件，已写入的部分保留用于下次续传
+	if msg.Offset != d.received {
+		global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("接收文件%s失败: 分块位置%d与已接收的%d字节不一致", f.Name, msg.Offset, d.received)}
+		d.file.Close()
+		d.index++
+		c.requestFile(conn)
+		return
+	}
+
+	if _, err := d.file.Write(msg.Data); err != nil {
+		global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("写入文件%s失败: %v", f.Name, err)}
+		d.file.Close()
+		os.Remove(d.file.Name())
+		d.index++
+		c.requestFile(conn)
+		return
+	}
+	d.received += int64(len(msg.Data))
+
+	// 每10%记录一次进度
+	if f.Size > 0 {
+		progress := d.received * 10 / f.Size
+		if progress != d.progress {
+			d.progress = progress
+			global_log_channel <- LogEntry{Kind: KindInfo, Content: fmt.Sprintf("接收文件%s: %d%%", f.Name, d.received*100/f.Size)}
+		}
+	}
+
+	if !msg.EOF {
+		return
+	}
+
+	d.file.Close()
+	// 文件名同样来自对方，不是本地文件名时使用文件ID
+	base := filepath.Base(f.Name)
+	if !filepath.IsLocal(base) {
+		base = f.ID
+	}
+	path := uniqueFilePath(filepath.Join(getDownloadDir(), base))
+	if err := os.Rename(d.file.Name(), path); err != nil {
+		global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("保存文件%s失败: %v", f.Name, err)}
+	} else {
+		d.paths = append(d.paths, path)
+	}
+	d.index++
+	c.requestFile(conn)
+}
+
+// 文件已存在时追加序号，避免覆盖
+func uniqueFilePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
+
 func (c *ShareClient) OnShared(callback func(item *ClipItem)){
 	c.onShare = callback
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 片段，独立于历史记录保存的文本模板
+type Snippet struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+	Pinned   bool   `json:"pinned"`
+}
+
+// 使用模板第一行作为默认名称
+func snippetName(template string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(template), "\n")
+	return truncateString(strings.TrimSpace(line), 20)
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// 展开模板中的占位符：
+// {date} {time} {datetime} {clipboard} {uuid} {cursor}
+// cursor 返回光标距离文本末尾的字符数，没有 {cursor} 时为 -1
+func expandSnippet(template string, clipboardText string) (text string, cursor int) {
+	now := time.Now()
+	replacer := strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("15:04:05"),
+		"{datetime}", now.Format("2006-01-02 15:04:05"),
+		"{clipboard}", clipboardText,
+		"{uuid}", newUUID(),
+	)
+
+	before, after, found := strings.Cut(template, "{cursor}")
+	if !found {
+		return replacer.Replace(template), -1
+	}
+	before, after = replacer.Replace(before), replacer.Replace(strings.ReplaceAll(after, "{cursor}", ""))
+	return before + after, len([]rune(after))
+}
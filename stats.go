@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// 历史记录的排序方式
+type HistoryOrder string
+
+const (
+	OrderTime     HistoryOrder = "time"      // 按时间
+	OrderMostUsed HistoryOrder = "most_used" // 按使用次数
+	OrderFrecency HistoryOrder = "frecency"  // 综合使用次数和最近使用时间
+)
+
+var history_orders = []struct {
+	Order HistoryOrder
+	Name  string
+}{
+	{OrderTime, "按时间"},
+	{OrderMostUsed, "最常用"},
+	{OrderFrecency, "常用且最近"},
+}
+
+// 使用记录的半衰期，超过半衰期的使用记录权重减半
+const frecency_half_life = 72 * time.Hour
+
+// 最近一次使用的时间，没有使用过时取复制时间
+func (c *ClipItem) lastActive() time.Time {
+	if c.LastUsed.After(c.Time) {
+		return c.LastUsed
+	}
+	return c.Time
+}
+
+func frecencyScore(item *ClipItem) float64 {
+	age := math.Max(time.Since(item.lastActive()).Hours(), 0)
+	return float64(1+item.UseCount) * math.Exp2(-age/frecency_half_life.Hours())
+}
+
+// 按排序方式返回新的条目列表，非时间排序时相同内容只保留最新的一条
+func orderItems(items []*ClipItem, order HistoryOrder) []*ClipItem {
+	if order != OrderMostUsed && order != OrderFrecency {
+		return items
+	}
+
+	seen := make(map[string]bool)
+	result := []*ClipItem{}
+	for _, item := range items {
+		key := fmt.Sprintf("%d:%s", item.Type, item.Hash)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, item)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if order == OrderFrecency {
+			return frecencyScore(result[i]) > frecencyScore(result[j])
+		}
+		if result[i].UseCount != result[j].UseCount {
+			return result[i].UseCount > result[j].UseCount
+		}
+		return result[i].lastActive().After(result[j].lastActive())
+	})
+	return result
+}
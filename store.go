@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 图片内容单独保存在配置文件旁的 images 目录，按内容哈希命名，配置文件中只保存条目信息
+// 相同的图片只写入一次，保存历史记录时不需要重新编码所有图片
+func getImageStoreDir() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), "images")
+}
+
+func imageStorePath(hash string) string {
+	return filepath.Join(getImageStoreDir(), strings.ToLower(hash)+".png")
+}
+
+// 将图片写入图片目录，返回不含图片内容的条目副本，写入失败的图片保留在配置文件中
+// stored 记录已保存的图片哈希，用于删除不再使用的图片
+func storeImages(items []*ClipItem, stored map[string]bool) []*ClipItem {
+	result := make([]*ClipItem, len(items))
+	for i, item := range items {
+		result[i] = item
+		if item.Type != TypeImage || !validHash(item.Hash) {
+			continue
+		}
+
+		hash := strings.ToLower(item.Hash)
+		if !stored[hash] {
+			path := imageStorePath(hash)
+			if _, err := os.Stat(path); err != nil {
+				if err := os.MkdirAll(getImageStoreDir(), 0755); err != nil {
+					global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("创建图片目录失败: %v", err)}
+					continue
+				}
+				if err := os.WriteFile(path, item.Content, 0644); err != nil {
+					global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("保存图片失败: %v", err)}
+					continue
+				}
+			}
+			stored[hash] = true
+		}
+
+		clone := item.Clone()
+		clone.Content = nil
+		result[i] = clone
+	}
+	return result
+}
+
+// 从图片目录读取配置文件中没有内容的图片，读取失败的条目被丢弃
+func loadStoredImages(items []*ClipItem) []*ClipItem {
+	result := make([]*ClipItem, 0, len(items))
+	for _, item := range items {
+		if item.Type == TypeImage && len(item.Content) == 0 {
+			if !validHash(item.Hash) {
+				continue
+			}
+			content, err := os.ReadFile(imageStorePath(item.Hash))
+			if err != nil {
+				global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("读取图片失败: %v", err)}
+				continue
+			}
+			item.Content = content
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// 删除图片目录中不再使用的图片，加密保存时 stored 为空，删除所有明文保存的图片
+func cleanImageStore(stored map[string]bool) {
+	entries, err := os.ReadDir(getImageStoreDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		hash, ok := strings.CutSuffix(entry.Name(), ".png")
+		if !ok || !validHash(hash) || stored[hash] {
+			continue
+		}
+		os.Remove(filepath.Join(getImageStoreDir(), entry.Name()))
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+type TimeFormat struct {
+	Name   string
+	Format func(t time.Time) string
+}
+
+// 可以复制的时间格式
+var time_formats = []TimeFormat{
+	{Name: "Unix秒", Format: func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }},
+	{Name: "Unix毫秒", Format: func(t time.Time) string { return strconv.FormatInt(t.UnixMilli(), 10) }},
+	{Name: "ISO 8601", Format: func(t time.Time) string { return t.Local().Format(time.RFC3339) }},
+	{Name: "本地时间", Format: func(t time.Time) string { return t.Local().Format("2006-01-02 15:04:05") }},
+	{Name: "UTC", Format: func(t time.Time) string { return t.UTC().Format(time.RFC3339) }},
+	{Name: "相对时间", Format: formatRelativeTime},
+}
+
+// 识别Unix时间戳（秒/毫秒）和常见的日期格式
+func parseTimestamp(text string) (time.Time, bool) {
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		var t time.Time
+		switch len(text) {
+		case 10:
+			t = time.Unix(n, 0)
+		case 13:
+			t = time.UnixMilli(n)
+		default:
+			return time.Time{}, false
+		}
+		// 只识别2000年到十年后的时间，避免把编号、订单号等普通数字当成时间戳
+		if t.Before(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)) || t.After(time.Now().AddDate(10, 0, 0)) {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, time.RFC3339, time.RFC1123Z, time.RFC1123} {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, true
+		}
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006/01/02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func formatRelativeTime(t time.Time) string {
+	d := time.Since(t)
+	suffix := "前"
+	if d < 0 {
+		d, suffix = -d, "后"
+	}
+
+	switch {
+	case d < 10*time.Second:
+		return "刚刚"
+	case d < time.Minute:
+		return fmt.Sprintf("%d秒%s", int(d.Seconds()), suffix)
+	case d < time.Hour:
+		return fmt.Sprintf("%d分钟%s", int(d.Minutes()), suffix)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d小时%s", int(d.Hours()), suffix)
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%d天%s", int(d.Hours()/24), suffix)
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%d个月%s", int(math.Round(d.Hours()/24/30)), suffix)
+	default:
+		return fmt.Sprintf("%d年%s", int(math.Round(d.Hours()/24/365)), suffix)
+	}
+}
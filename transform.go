@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 文本转换
+type Transformer struct {
+	Name  string
+	Apply func(text string) (string, error)
+}
+
+var text_transformers = []Transformer{}
+
+func RegisterTransformer(name string, apply func(text string) (string, error)) {
+	text_transformers = append(text_transformers, Transformer{Name: name, Apply: apply})
+}
+
+func init() {
+	RegisterTransformer("去除首尾空白", func(text string) (string, error) {
+		return strings.TrimSpace(text), nil
+	})
+	RegisterTransformer("大写", func(text string) (string, error) {
+		return strings.ToUpper(text), nil
+	})
+	RegisterTransformer("小写", func(text string) (string, error) {
+		return strings.ToLower(text), nil
+	})
+	RegisterTransformer("首字母大写", func(text string) (string, error) {
+		return titleCase(text), nil
+	})
+	RegisterTransformer("URL编码", func(text string) (string, error) {
+		return url.QueryEscape(text), nil
+	})
+	RegisterTransformer("URL解码", func(text string) (string, error) {
+		return url.QueryUnescape(text)
+	})
+	RegisterTransformer("Base64编码", func(text string) (string, error) {
+		return base64.StdEncoding.EncodeToString([]byte(text)), nil
+	})
+	RegisterTransformer("Base64解码", decodeBase64)
+	RegisterTransformer("JSON格式化", func(text string) (string, error) {
+		var buf bytes.Buffer
+		err := json.Indent(&buf, []byte(text), "", "  ")
+		return buf.String(), err
+	})
+	RegisterTransformer("JSON压缩", func(text string) (string, error) {
+		var buf bytes.Buffer
+		err := json.Compact(&buf, []byte(text))
+		return buf.String(), err
+	})
+	RegisterTransformer("转义", func(text string) (string, error) {
+		quoted := strconv.Quote(text)
+		return quoted[1 : len(quoted)-1], nil
+	})
+	RegisterTransformer("反转义", func(text string) (string, error) {
+		if result, err := strconv.Unquote(`"` + text + `"`); err == nil {
+			return result, nil
+		}
+		// 文本中包含未转义的双引号
+		return strconv.Unquote(`"` + strings.ReplaceAll(text, `"`, `\"`) + `"`)
+	})
+	RegisterTransformer("行排序", func(text string) (string, error) {
+		lines := strings.Split(text, "\n")
+		sort.Strings(lines)
+		return strings.Join(lines, "\n"), nil
+	})
+	RegisterTransformer("行去重", func(text string) (string, error) {
+		seen := make(map[string]bool)
+		lines := []string{}
+		for _, line := range strings.Split(text, "\n") {
+			if !seen[line] {
+				seen[line] = true
+				lines = append(lines, line)
+			}
+		}
+		return strings.Join(lines, "\n"), nil
+	})
+}
+
+func titleCase(text string) string {
+	runes := []rune(text)
+	for i, r := range runes {
+		if i == 0 || unicode.IsSpace(runes[i-1]) {
+			runes[i] = unicode.ToUpper(r)
+		} else {
+			runes[i] = unicode.ToLower(r)
+		}
+	}
+	return string(runes)
+}
+
+// 依次尝试标准和URL安全的编码，允许省略填充
+func decodeBase64(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if data, err := encoding.DecodeString(text); err == nil {
+			return string(data), nil
+		}
+	}
+	return "", fmt.Errorf("不是有效的Base64")
+}
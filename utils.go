@@ -1,9 +1,13 @@
 package main
 
 import (
+	"fmt"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
-	"regexp"
+	"runtime"
+	"strings"
 )
 
 func Ifel[T any](ok bool, a T, b T) T {
@@ -31,6 +35,10 @@ func getConfigPath() string {
     return filepath.Join(appDir, "config.json")
 }
 
+func getApiPath() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), "api.json")
+}
+
 func getLogPath() string {
 	// 获取可执行文件路径
     execPath, err := os.Executable()
@@ -43,22 +51,112 @@ func getLogPath() string {
     return filepath.Join(appDir, ".log")
 }
 
-func getColor(str string) (r string, g string, b string, base int, ok bool) {
-	pattern := `(?i)^(?:#(?:([\da-f][\da-f])([\da-f][\da-f])([\da-f][\da-f])|([\da-f])([\da-f])([\da-f]))|\(?(\d|1?\d{2}|2[0-5]{2})\s*,\s*(\d|1?\d{2}|2[0-5]{2})\s*,\s*(\d|1?\d{2}|2[0-5]{2})(?:\s*,\s*(?:\d|1?\d{2}|2[0-5]{2}))?\)?)$`
-	reg, err := regexp.Compile(pattern)
-	if err != nil{
-		return "", "", "", 0, false
+// 启动外部命令，不等待结束
+func startCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// 使用系统默认程序打开链接或文件
+func openExternal(target string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return startCommand("open", target)
+	case "windows":
+		return startCommand("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		return startCommand("xdg-open", target)
+	}
+}
+
+// 显示系统通知
+func notify(title string, message string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", message, title)
+		return startCommand("osascript", "-e", script)
+	case "windows":
+		script := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms; $n = New-Object System.Windows.Forms.NotifyIcon; $n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; $n.ShowBalloonTip(5000, '%s', '%s', 'Info'); Start-Sleep 5; $n.Dispose()`,
+			strings.ReplaceAll(title, "'", "''"), strings.ReplaceAll(message, "'", "''"))
+		return startCommand("powershell", "-NoProfile", "-WindowStyle", "Hidden", "-Command", script)
+	default:
+		return startCommand("notify-send", title, message)
 	}
+}
 
-	groups := reg.FindStringSubmatch(str)
-	if len(groups) < 9 {
-		return "", "", "", 0, false
+// 记录错误并通知用户
+func reportError(message string) {
+	global_log_channel <- LogEntry{Kind: KindError, Content: message}
+	if err := notify("Clip", message); err != nil {
+		global_log_channel <- LogEntry{Kind: KindError, Content: fmt.Sprintf("显示通知失败: %v", err)}
 	}
+}
 
-	r = Ifel(groups[1] != "", groups[1], Ifel(groups[4] != "", groups[4], Ifel(groups[7] != "", groups[7], "")))
-	g = Ifel(groups[2] != "", groups[2], Ifel(groups[5] != "", groups[5], Ifel(groups[8] != "", groups[8], "")))
-	b = Ifel(groups[3] != "", groups[3], Ifel(groups[6] != "", groups[6], Ifel(groups[9] != "", groups[9], "")))
-	ok = r != "" && g != "" && b != ""
+func getDownloadDir() string {
+	if config_share_download_dir != "" {
+		return config_share_download_dir
+	}
 
-	return r, g, b, Ifel(groups[1] != "", 16, 10), ok
+	// 默认使用用户下载目录
+	home, err := os.UserHomeDir()
+	if err == nil {
+		return filepath.Join(home, "Downloads", "Clip")
+	}
+
+	execPath, err := os.Executable()
+	if err != nil {
+		return "downloads" // 降级到当前目录
+	}
+	return filepath.Join(filepath.Dir(execPath), "downloads")
+}
+
+// 解析文件列表，每行一个绝对路径或 file:// 地址，且必须都是存在的普通文件
+func parseFileList(text string) ([]string, bool) {
+	paths := []string{}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "file://") {
+			u, err := url.Parse(line)
+			if err != nil || (u.Host != "" && u.Host != "localhost") {
+				return nil, false
+			}
+			line = u.Path
+			// Windows 的 file:///C:/x 解析后为 /C:/x，去掉盘符前的斜杠
+			if runtime.GOOS == "windows" && len(line) >= 3 && line[0] == '/' && line[2] == ':' {
+				line = line[1:]
+			}
+			line = filepath.FromSlash(line)
+		}
+
+		if !filepath.IsAbs(line) {
+			return nil, false
+		}
+		info, err := os.Stat(line)
+		if err != nil || !info.Mode().IsRegular() {
+			return nil, false
+		}
+		paths = append(paths, line)
+	}
+	return paths, len(paths) > 0
+}
+
+// 是否为 MD5 的32位十六进制形式，条目哈希和共享文件ID用作文件名或路径前检查
+func validHash(hash string) bool {
+	if len(hash) != 32 {
+		return false
+	}
+	for _, r := range hash {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F') {
+			return false
+		}
+	}
+	return true
 }